@@ -0,0 +1,71 @@
+package file_storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ACL 对象访问权限
+type ACL string
+
+const (
+	// ACLDefault 继承存储桶的访问权限
+	ACLDefault ACL = ""
+	// ACLPrivate 私有读写，FileUrl 为带有效期的签名 URL
+	ACLPrivate ACL = "private"
+	// ACLPublicRead 公共读，私有写
+	ACLPublicRead ACL = "public-read"
+)
+
+// defaultURLExpires 签名 URL 的默认有效期
+const defaultURLExpires = time.Hour
+
+func (a ACL) validate() error {
+	switch a {
+	case ACLPrivate, ACLPublicRead:
+		return nil
+	}
+
+	return fmt.Errorf("%w: acl %q", UnsupportedOptionErr, a)
+}
+
+// WithACL 设置对象的访问权限，覆盖驱动配置，七牛驱动不支持
+func WithACL(acl ACL) UploadOption {
+	return func(o *UploadOptions) {
+		o.ACL = acl
+	}
+}
+
+// acl 返回本次上传生效的访问权限，ACLDefault 表示不设置
+func (o *UploadOptions) acl(def ACL) (ACL, error) {
+	acl := def
+	if o.ACL != ACLDefault {
+		acl = o.ACL
+	}
+
+	if acl == ACLDefault {
+		return acl, nil
+	}
+
+	return acl, acl.validate()
+}
+
+// urlExpires 未配置时使用默认有效期
+func urlExpires(expires time.Duration) time.Duration {
+	if expires <= 0 {
+		return defaultURLExpires
+	}
+
+	return expires
+}
+
+// SetACL 修改已有对象的访问权限
+func (u *Uploader) SetACL(ctx context.Context, path string, acl ACL) error {
+	err := u.uploader.SetACL(ctx, path, acl)
+	if err != nil {
+		u.logger.Errorf("set acl %s err: %v", path, err)
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package file_storage
+
+import (
+	"context"
+	"mime/multipart"
+	"sync"
+)
+
+// defaultBatchWorkers 批量上传默认并发数
+const defaultBatchWorkers = 4
+
+// BatchResult 批量上传中单个文件的结果
+type BatchResult struct {
+	FileName string
+	Result   UploadResult
+	Err      error
+}
+
+func (u *Uploader) BatchUpload(ctx context.Context, files []*multipart.FileHeader, randomName bool, workers int, opts ...UploadOption) []BatchResult {
+	sources := make([]Source, len(files))
+	for i, file := range files {
+		sources[i] = FileHeaderSource(file)
+	}
+
+	return u.BatchUploadSource(ctx, sources, randomName, workers, opts...)
+}
+
+// BatchUploadSource 并发上传多个数据源，workers 小于等于 0 时使用默认并发数
+// 单个文件失败不影响其他文件，结果与 files 顺序一致；ctx 取消后尚未开始的文件返回 ctx.Err()
+// 不重新生成文件名时同名文件会写入同一路径，按顺序逐个上传，避免并发写入同一文件
+func (u *Uploader) BatchUploadSource(ctx context.Context, files []Source, randomName bool, workers int, opts ...UploadOption) []BatchResult {
+	results := make([]BatchResult, len(files))
+	for i, file := range files {
+		results[i].FileName = file.Name()
+	}
+
+	groups := batchGroups(files, randomName)
+
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
+	workers = min(workers, len(groups))
+
+	indexes := make(chan []int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for group := range indexes {
+				for _, index := range group {
+					if err := ctx.Err(); err != nil {
+						results[index].Err = err
+						continue
+					}
+
+					results[index].Result, results[index].Err = u.UploadSource(ctx, files[index], randomName, opts...)
+				}
+			}
+		}()
+	}
+
+	for _, group := range groups {
+		indexes <- group
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	return results
+}
+
+// batchGroups 将同名文件分为一组，组内按原顺序上传，重新生成文件名时每个文件单独一组
+func batchGroups(files []Source, randomName bool) [][]int {
+	groups := make([][]int, 0, len(files))
+	named := make(map[string]int)
+	for i, file := range files {
+		if !randomName {
+			if n, ok := named[file.Name()]; ok {
+				groups[n] = append(groups[n], i)
+				continue
+			}
+			named[file.Name()] = len(groups)
+		}
+		groups = append(groups, []int{i})
+	}
+
+	return groups
+}
@@ -0,0 +1,61 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestBatchUpload(t *testing.T) {
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	uploader := NewFileUploader().RegisterUploader(local).SetValidation(ValidationRules{MaxSize: 10})
+
+	var files []Source
+	for i := 0; i < 10; i++ {
+		files = append(files, newSource(t, fmt.Sprintf("%d.txt", i), []byte("hello")))
+	}
+	files[3] = newSource(t, "3.txt", bytes.Repeat([]byte("a"), 20))
+
+	results := uploader.BatchUploadSource(context.Background(), files, false, 3)
+	for i, res := range results {
+		if res.FileName != files[i].Name() {
+			t.Fatalf("result %d out of order: %s", i, res.FileName)
+		}
+		if i == 3 {
+			if !errors.Is(res.Err, FileTooLargeErr) {
+				t.Fatalf("expected FileTooLargeErr, got %v", res.Err)
+			}
+			continue
+		}
+		if res.Err != nil || res.Result.Path == "" {
+			t.Fatalf("file %d: %v", i, res.Err)
+		}
+	}
+
+	// 同名文件按顺序上传，最终内容为最后一个
+	var dups []Source
+	for i := 0; i < 5; i++ {
+		dups = append(dups, newSource(t, "dup.txt", []byte(fmt.Sprintf("content %d", i))))
+	}
+	results = uploader.BatchUploadSource(context.Background(), dups, false, 3)
+	for _, res := range results {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+	}
+	if data, _ := os.ReadFile(results[0].Result.Path); string(data) != "content 4" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, res := range uploader.BatchUploadSource(ctx, files, false, 3) {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", res.Err)
+		}
+	}
+}
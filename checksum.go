@@ -0,0 +1,176 @@
+package file_storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// Checksum 上传内容的校验值
+type Checksum struct {
+	// MD5 hex 编码
+	MD5 string `json:"md5"`
+	// SHA256 hex 编码
+	SHA256 string `json:"sha256"`
+	// CRC64 ECMA 多项式，与 OSS/COS 返回的 crc64ecma 一致
+	CRC64 uint64 `json:"crc64"`
+}
+
+// ContentMD5 返回 Content-MD5 请求头使用的 base64 编码
+func (c Checksum) ContentMD5() string {
+	b, err := hex.DecodeString(c.MD5)
+	if err != nil {
+		return ""
+	}
+
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// checksumWriter 同时计算 MD5、SHA-256 和 CRC64
+type checksumWriter struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	crc64  hash.Hash64
+}
+
+func newChecksumWriter() *checksumWriter {
+	return &checksumWriter{
+		md5:    md5.New(),
+		sha256: sha256.New(),
+		crc64:  crc64.New(crc64Table),
+	}
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	w.md5.Write(p)
+	w.sha256.Write(p)
+	w.crc64.Write(p)
+
+	return len(p), nil
+}
+
+func (w *checksumWriter) Sum() Checksum {
+	return Checksum{
+		MD5:    hex.EncodeToString(w.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(w.sha256.Sum(nil)),
+		CRC64:  w.crc64.Sum64(),
+	}
+}
+
+// checksumReader 在 SDK 读取上传内容的同时计算校验值
+// SDK 重试时会回到开头重新读取，已计算过的部分不再重复计算
+type checksumReader struct {
+	r      io.ReadSeeker
+	w      *checksumWriter
+	pos    int64
+	hashed int64
+}
+
+func newChecksumReader(r io.ReadSeeker) *checksumReader {
+	return &checksumReader{r: r, w: newChecksumWriter()}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if end := r.pos + int64(n); r.pos <= r.hashed && end > r.hashed {
+		r.w.Write(p[r.hashed-r.pos : n])
+		r.hashed = end
+	}
+	r.pos += int64(n)
+
+	return n, err
+}
+
+func (r *checksumReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.r.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+
+	return pos, err
+}
+
+func (r *checksumReader) Sum() Checksum {
+	return r.w.Sum()
+}
+
+// computeChecksum 读取整个文件计算校验值，读取后回到文件开头，extra 可附加其他摘要计算
+// 仅用于 SDK 并发按位置读取、无法边读边计算的场景
+func computeChecksum(fd io.ReadSeeker, extra ...io.Writer) (Checksum, error) {
+	w := newChecksumWriter()
+	if _, err := io.Copy(io.MultiWriter(append([]io.Writer{w}, extra...)...), fd); err != nil {
+		return Checksum{}, err
+	}
+
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		return Checksum{}, err
+	}
+
+	return w.Sum(), nil
+}
+
+// partChecksum 计算分片的 MD5，同时把分片内容写入整体校验，读取后回到分片开头
+func partChecksum(part io.ReadSeeker, w *checksumWriter) ([]byte, error) {
+	h := md5.New()
+	if _, err := io.Copy(io.MultiWriter(h, w), part); err != nil {
+		return nil, err
+	}
+
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// multipartETag S3 兼容存储分片上传完成后的 ETag：md5(各分片 md5 拼接)-分片数
+func multipartETag(partMD5s [][]byte) string {
+	h := md5.New()
+	for _, v := range partMD5s {
+		h.Write(v)
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partMD5s))
+}
+
+// verifyETag 对比服务端返回的 ETag，分片上传的 ETag 与预期格式不一致时不做比较
+// SSE-KMS、SSE-C 加密后的 ETag 格式相同但不是内容 MD5，调用方需确认对象未使用这两种加密
+func verifyETag(etag, expect string) error {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if etag == "" || strings.Contains(etag, "-") != strings.Contains(expect, "-") {
+		return nil
+	}
+
+	if etag != expect {
+		return fmt.Errorf("%w: etag %s, expect %s", ChecksumMismatchErr, etag, expect)
+	}
+
+	return nil
+}
+
+// verifyCRC64 对比服务端返回的 crc64ecma 响应头，未返回时不做比较
+func verifyCRC64(header string, expect uint64) error {
+	if header == "" {
+		return nil
+	}
+
+	crc, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	if crc != expect {
+		return fmt.Errorf("%w: crc64 %d, expect %d", ChecksumMismatchErr, crc, expect)
+	}
+
+	return nil
+}
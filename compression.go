@@ -0,0 +1,311 @@
+package file_storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/qiuyier/file-storage/pkg/util"
+	"io"
+	"mime"
+	"strconv"
+)
+
+type CompressionAlgorithm string
+
+const (
+	Gzip CompressionAlgorithm = "gzip"
+	Zstd CompressionAlgorithm = "zstd"
+)
+
+// 压缩对象的元数据，记录压缩算法及原始大小
+const (
+	MetaCompression  = "fs-compression"
+	MetaOriginalSize = "fs-original-size"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// defaultSkipTypes 本身已压缩的格式，再次压缩几乎没有收益
+var defaultSkipTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"image/heic",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/pdf",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/vnd.rar",
+	"application/epub+zip",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// CompressedUploader 压缩包装，上传时使用 gzip 或 zstd 压缩并设置 Content-Encoding，GetObject 时透明解压，可包装任意驱动
+//
+// 压缩结果通过管道作为长度未知的数据流交给驱动上传，不会写入临时文件或把文件整体读入内存。
+// 已设置 Content-Encoding、属于已压缩格式的文件按原样上传；长度已知的文件先压缩一遍统计长度，没有变小时按原样上传。
+// 数据流只能读取一次，总是上传压缩结果，且无法预知原始大小，不记录 MetaOriginalSize。
+// 与 EncryptedUploader 同时使用时需先压缩后加密，即 NewCompressedUploader(NewEncryptedUploader(...))。
+type CompressedUploader struct {
+	IUpload
+	algorithm CompressionAlgorithm
+	skipTypes []string
+}
+
+func NewCompressedUploader(uploader IUpload, algorithm CompressionAlgorithm) *CompressedUploader {
+	return &CompressedUploader{
+		IUpload:   uploader,
+		algorithm: algorithm,
+		skipTypes: defaultSkipTypes,
+	}
+}
+
+// SetSkipTypes 设置不压缩的 MIME 类型，支持 image/* 形式的通配
+func (u *CompressedUploader) SetSkipTypes(types ...string) *CompressedUploader {
+	u.skipTypes = types
+	return u
+}
+
+func (u *CompressedUploader) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	file, opts, cleanup, err := u.compress(file, opts)
+	if err != nil {
+		return res, err
+	}
+	defer cleanup()
+
+	return u.IUpload.Upload(ctx, file, randomly, opts...)
+}
+
+func (u *CompressedUploader) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	file, opts, cleanup, err := u.compress(file, opts)
+	if err != nil {
+		return res, err
+	}
+	defer cleanup()
+
+	return u.IUpload.MultipartUpload(ctx, file, randomly, chunkSize, opts...)
+}
+
+// StatObject 压缩对象记录了原始大小时返回原始大小，数据流压缩后上传的对象返回压缩后的大小
+func (u *CompressedUploader) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	info, err := u.IUpload.StatObject(ctx, path, opts...)
+	if err != nil || info.Metadata[MetaCompression] == "" {
+		return info, err
+	}
+
+	if size, err := strconv.ParseInt(info.Metadata[MetaOriginalSize], 10, 64); err == nil {
+		info.Size = size
+	}
+
+	return info, nil
+}
+
+// GetObject 下载对象，按元数据记录的算法解压，未压缩的对象原样返回
+// 指定范围时，压缩对象从头解压后截取，范围是解压后内容的位置
+func (u *CompressedUploader) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	info, err := u.IUpload.StatObject(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := CompressionAlgorithm(info.Metadata[MetaCompression])
+	if algorithm == "" {
+		return u.IUpload.GetObject(ctx, path, opts...)
+	}
+
+	rc, err := u.IUpload.GetObject(ctx, path, withoutRange(opts)...)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newDecompressReader(rc, algorithm)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	return rangeReadCloser(r, newObjectOptions(opts...))
+}
+
+// compress 返回边读取边压缩的数据源，以及上传结束后停止压缩的函数
+func (u *CompressedUploader) compress(file Source, opts []UploadOption) (Source, []UploadOption, func(), error) {
+	noop := func() {}
+
+	o := newUploadOptions(opts...)
+	if o.ContentEncoding != "" {
+		return file, opts, noop, nil
+	}
+
+	contentType := o.ContentType
+	if contentType == "" {
+		fd, err := file.Open()
+		if err != nil {
+			return nil, nil, noop, errors.New("open file " + file.Name() + ", err: " + err.Error())
+		}
+		contentType = util.SniffContentType(fd, file.Name())
+		_ = fd.Close()
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	if matchMediaType(u.skipTypes, mediaType) {
+		return file, opts, noop, nil
+	}
+
+	metadata := map[string]string{MetaCompression: string(u.algorithm)}
+
+	// 长度已知时先压缩一遍只统计长度，没有变小则按原样上传
+	if file.Size() >= 0 {
+		_, size, err := u.compressTo(io.Discard, file)
+		if err != nil {
+			return nil, nil, noop, fmt.Errorf("compress file %s, err: %w", file.Name(), err)
+		}
+		if size >= file.Size() {
+			return file, opts, noop, nil
+		}
+		metadata[MetaOriginalSize] = strconv.FormatInt(file.Size(), 10)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, err := u.compressTo(pw, file)
+		if err != nil {
+			err = fmt.Errorf("compress file %s, err: %w", file.Name(), err)
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	// 驱动未读取完就返回时关闭管道，使压缩停止
+	cleanup := func() {
+		_ = pr.Close()
+		<-done
+	}
+
+	opts = append(opts[:len(opts):len(opts)],
+		WithContentType(contentType),
+		WithContentEncoding(string(u.algorithm)),
+		WithMetadata(metadata),
+	)
+
+	return NewStreamSource(file.Name(), pr), opts, cleanup, nil
+}
+
+// compressTo 返回原始长度和压缩后的长度
+func (u *CompressedUploader) compressTo(dst io.Writer, file Source) (int64, int64, error) {
+	fd, err := file.Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer fd.Close()
+
+	counter := &countWriter{w: dst}
+
+	var w io.WriteCloser
+	switch u.algorithm {
+	case Gzip:
+		w = gzip.NewWriter(counter)
+	case Zstd:
+		if w, err = zstd.NewWriter(counter); err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("%w: compression %q", UnsupportedOptionErr, u.algorithm)
+	}
+
+	n, err := io.Copy(w, fd)
+	if err != nil {
+		_ = w.Close()
+		return 0, 0, err
+	}
+
+	if err = w.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	return n, counter.n, nil
+}
+
+// countWriter 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// decompressReader 解压对象内容，关闭时同时关闭解压器和原始响应
+type decompressReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// newDecompressReader 部分 HTTP 客户端会按 Content-Encoding 自动解压，内容开头不是压缩格式标识时视为已解压
+func newDecompressReader(rc io.ReadCloser, algorithm CompressionAlgorithm) (io.ReadCloser, error) {
+	r := bufio.NewReader(rc)
+
+	switch algorithm {
+	case Gzip:
+		if head, _ := r.Peek(len(gzipMagic)); !bytes.Equal(head, gzipMagic) {
+			return &decompressReader{Reader: r, closers: []io.Closer{rc}}, nil
+		}
+
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressReader{Reader: gr, closers: []io.Closer{gr, rc}}, nil
+	case Zstd:
+		if head, _ := r.Peek(len(zstdMagic)); !bytes.Equal(head, zstdMagic) {
+			return &decompressReader{Reader: r, closers: []io.Closer{rc}}, nil
+		}
+
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		dr := zr.IOReadCloser()
+		return &decompressReader{Reader: dr, closers: []io.Closer{dr, rc}}, nil
+	}
+
+	return nil, fmt.Errorf("%w: compression %q", UnsupportedOptionErr, algorithm)
+}
+
+func (d *decompressReader) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompressedUploader(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(strings.Repeat(`{"level":"info","msg":"request done"}`+"\n", 1000))
+
+	for _, algorithm := range []CompressionAlgorithm{Gzip, Zstd} {
+		local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+		uploader := NewCompressedUploader(local, algorithm)
+
+		res, err := uploader.Upload(ctx, newSource(t, "app.log", content), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored, _ := os.ReadFile(res.Path)
+		if len(stored) >= len(content) {
+			t.Fatalf("%s: not compressed, %d bytes", algorithm, len(stored))
+		}
+
+		meta, _ := local.readMeta(res.Path)
+		if meta.ContentEncoding != string(algorithm) || meta.Metadata[MetaCompression] != string(algorithm) {
+			t.Fatalf("%s: unexpected meta %+v", algorithm, meta)
+		}
+		if info, err := uploader.StatObject(ctx, res.Path); err != nil || info.Size != int64(len(content)) {
+			t.Fatalf("%s: unexpected stat %+v, %v", algorithm, info, err)
+		}
+
+		rc, err := uploader.GetObject(ctx, res.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("%s: round trip failed, err %v", algorithm, err)
+		}
+
+		// 数据流总是上传压缩结果，不记录原始大小
+		res, err = uploader.Upload(ctx, NewStreamSource("stream.log", bytes.NewReader(content)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, _ = local.readMeta(res.Path)
+		if _, ok := meta.Metadata[MetaOriginalSize]; ok || meta.Metadata[MetaCompression] != string(algorithm) {
+			t.Fatalf("%s: unexpected stream meta %+v", algorithm, meta)
+		}
+		rc, err = uploader.GetObject(ctx, res.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("%s: stream round trip failed, err %v", algorithm, err)
+		}
+	}
+}
+
+func TestCompressedUploaderSkipTypes(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	uploader := NewCompressedUploader(local, Gzip)
+
+	content := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 4096)...)
+	res, err := uploader.Upload(ctx, newSource(t, "a.png", content), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := os.ReadFile(res.Path)
+	if !bytes.Equal(stored, content) {
+		t.Fatal("png should be stored as is")
+	}
+
+	rc, err := uploader.GetObject(ctx, res.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if !bytes.Equal(got, content) {
+		t.Fatal("unexpected content")
+	}
+}
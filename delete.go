@@ -0,0 +1,86 @@
+package file_storage
+
+import (
+	"errors"
+)
+
+// maxDeleteBatch 各厂商批量删除单次请求的最大数量
+const maxDeleteBatch = 1000
+
+// DeleteError 单个对象删除失败的原因，可通过 errors.Is 判断 ObjectNotFoundErr、AccessDeniedErr 等
+type DeleteError struct {
+	Path string
+	// Code 厂商返回的错误码，请求整体失败时为空
+	Code string
+	Err  error
+}
+
+func (e *DeleteError) Error() string {
+	return "delete " + e.Path + ", err: " + e.Err.Error()
+}
+
+func (e *DeleteError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteResult 批量删除结果，不存在的对象视为删除成功，与 S3 语义一致
+type DeleteResult struct {
+	Deleted []string
+	Failed  []*DeleteError
+}
+
+// Err 存在删除失败的对象时返回合并后的错误
+func (r *DeleteResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(r.Failed))
+	for i, v := range r.Failed {
+		errs[i] = v
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *DeleteResult) fail(path, code string, err error) {
+	r.Failed = append(r.Failed, &DeleteError{Path: path, Code: code, Err: err})
+}
+
+// failAll 整个请求失败时，本批次的对象全部记为失败
+func (r *DeleteResult) failAll(paths []string, err error) {
+	for _, path := range paths {
+		r.fail(path, "", err)
+	}
+}
+
+// deleteCodeErr 将厂商返回的错误码转换为错误
+func deleteCodeErr(code, message string) error {
+	switch code {
+	case "NoSuchKey":
+		return ObjectNotFoundErr
+	case "AccessDenied":
+		return AccessDeniedErr
+	}
+
+	if message == "" {
+		message = code
+	}
+
+	return errors.New(message)
+}
+
+// chunkPaths 按单次请求上限切分
+func chunkPaths(paths []string, size int) [][]string {
+	var chunks [][]string
+	for len(paths) > size {
+		chunks = append(chunks, paths[:size:size])
+		paths = paths[size:]
+	}
+
+	if len(paths) > 0 {
+		chunks = append(chunks, paths)
+	}
+
+	return chunks
+}
@@ -0,0 +1,355 @@
+package file_storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDownloadPartSize 默认每个范围请求的大小
+	defaultDownloadPartSize = 8 << 20
+	// defaultDownloadWorkers 默认并发下载数
+	defaultDownloadWorkers = 4
+)
+
+// DownloadConfig 下载到本地文件的配置，零值字段使用默认值
+type DownloadConfig struct {
+	// PartSize 每个范围请求的大小，单位byte，默认 8M
+	PartSize int64
+	// Workers 并发下载数，默认 4
+	Workers int
+	// CheckpointFile 断点记录文件，默认为目标文件加 .checkpoint 后缀，中断后再次下载同一对象时跳过已完成的部分
+	CheckpointFile string
+	// VersionID 下载指定版本，为空时下载最新版本
+	VersionID string
+	// MD5 期望的内容 MD5，如上传结果的 Checksum.MD5；为空时本地存储与记录的 MD5 比较，七牛与 hash 比较，
+	// 其他驱动的 ETag 在 SSE-KMS、SSE-C 加密时不是内容 MD5，只校验大小
+	MD5 string
+	// SkipHashCheck 不校验内容
+	SkipHashCheck bool
+	// Progress 下载进度回调
+	Progress ProgressListener
+}
+
+// downloadCheckpoint 断点记录，对象发生变化或分片大小改变时重新下载
+type downloadCheckpoint struct {
+	Path         string    `json:"path"`
+	VersionID    string    `json:"version_id"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+	PartSize     int64     `json:"part_size"`
+	Completed    []bool    `json:"completed"`
+}
+
+// DownloadFile 将对象按范围并发下载到本地文件，先写入 filePath.download 临时文件，校验大小和内容后再重命名
+// 下载失败时保留临时文件和断点记录，使用相同配置再次调用会继续下载
+// 压缩、加密包装写入的对象长度与内容不一致，只能顺序下载
+func (u *Uploader) DownloadFile(ctx context.Context, path, filePath string, config DownloadConfig) error {
+	if err := u.downloadFile(ctx, path, filePath, config); err != nil {
+		u.logger.Errorf("download file err: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (u *Uploader) downloadFile(ctx context.Context, path, filePath string, config DownloadConfig) error {
+	if config.PartSize <= 0 {
+		config.PartSize = defaultDownloadPartSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultDownloadWorkers
+	}
+	if config.CheckpointFile == "" {
+		config.CheckpointFile = filePath + ".checkpoint"
+	}
+
+	var opts []ObjectOption
+	if config.VersionID != "" {
+		opts = append(opts, WithVersionID(config.VersionID))
+	}
+
+	info, err := u.uploader.StatObject(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filePath + ".download"
+
+	if info.Metadata[MetaCompression] != "" || info.Metadata[MetaEncryption] != "" {
+		if err = u.downloadSequential(ctx, path, tmpPath, opts, config.Progress); err != nil {
+			return err
+		}
+		// 内容经过解码，只能与调用方提供的 MD5 比较
+		info.Size = -1
+		info.ETag = ""
+	} else if err = u.downloadParts(ctx, path, tmpPath, info, opts, config); err != nil {
+		return err
+	}
+
+	if err = u.verifyDownload(tmpPath, info, config); err != nil {
+		_ = os.Remove(tmpPath)
+		_ = os.Remove(config.CheckpointFile)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return errors.New("rename file " + tmpPath + ", err: " + err.Error())
+	}
+	_ = os.Remove(config.CheckpointFile)
+
+	return nil
+}
+
+// downloadSequential 单个请求顺序下载
+func (u *Uploader) downloadSequential(ctx context.Context, path, tmpPath string, opts []ObjectOption, listener ProgressListener) error {
+	rc, err := u.GetObject(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.New("create file " + tmpPath + ", err: " + err.Error())
+	}
+	defer file.Close()
+
+	progress := newProgressTracker(listener, -1)
+
+	var w io.Writer = file
+	if progress != nil {
+		w = io.MultiWriter(file, progress)
+	}
+
+	if _, err = io.Copy(w, rc); err != nil {
+		return fmt.Errorf("download %s, err: %w", path, err)
+	}
+
+	progress.completed()
+
+	return file.Close()
+}
+
+// downloadParts 按范围并发下载，每完成一个分片更新一次断点记录
+func (u *Uploader) downloadParts(ctx context.Context, path, tmpPath string, info ObjectInfo, opts []ObjectOption, config DownloadConfig) error {
+	cp := loadDownloadCheckpoint(config.CheckpointFile, tmpPath, downloadCheckpoint{
+		Path:         path,
+		VersionID:    config.VersionID,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		PartSize:     config.PartSize,
+		Completed:    make([]bool, (info.Size+config.PartSize-1)/config.PartSize),
+	})
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return errors.New("open file " + tmpPath + ", err: " + err.Error())
+	}
+	defer file.Close()
+
+	if err = file.Truncate(info.Size); err != nil {
+		return errors.New("truncate file " + tmpPath + ", err: " + err.Error())
+	}
+
+	progress := newProgressTracker(config.Progress, info.Size)
+	for n, done := range cp.Completed {
+		if done {
+			progress.add(min(cp.PartSize, info.Size-int64(n)*cp.PartSize))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		parts    = make(chan int)
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for n := range parts {
+				if err := u.downloadPart(ctx, path, file, n, cp, opts, progress); err != nil {
+					fail(err)
+					continue
+				}
+
+				mu.Lock()
+				cp.Completed[n] = true
+				err := cp.save(config.CheckpointFile)
+				mu.Unlock()
+
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for n, done := range cp.Completed {
+		if done {
+			continue
+		}
+
+		select {
+		case parts <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(parts)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return errors.New("close file " + tmpPath + ", err: " + err.Error())
+	}
+
+	progress.completed()
+
+	return nil
+}
+
+// downloadPart 下载第 n 个分片并写入文件对应位置
+func (u *Uploader) downloadPart(ctx context.Context, path string, file *os.File, n int, cp *downloadCheckpoint, opts []ObjectOption, progress *progressTracker) error {
+	offset := int64(n) * cp.PartSize
+	length := min(cp.PartSize, cp.Size-offset)
+
+	rc, err := u.GetObject(ctx, path, append(opts[:len(opts):len(opts)], WithRange(offset, length))...)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var w io.Writer = io.NewOffsetWriter(file, offset)
+	if progress != nil {
+		w = io.MultiWriter(w, progress)
+	}
+
+	written, err := io.CopyN(w, rc, length)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("download %s part %d, err: %w", path, n+1, err)
+	}
+	if written != length {
+		return fmt.Errorf("%w: part %d size %d, expect %d", ChecksumMismatchErr, n+1, written, length)
+	}
+
+	return nil
+}
+
+// verifyDownload 校验文件大小及内容，info.Size 小于 0 时不校验大小
+func (u *Uploader) verifyDownload(tmpPath string, info ObjectInfo, config DownloadConfig) error {
+	file, err := os.Open(tmpPath)
+	if err != nil {
+		return errors.New("open file " + tmpPath + ", err: " + err.Error())
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return errors.New("stat file " + tmpPath + ", err: " + err.Error())
+	}
+	if info.Size >= 0 && stat.Size() != info.Size {
+		return fmt.Errorf("%w: size %d, expect %d", ChecksumMismatchErr, stat.Size(), info.Size)
+	}
+
+	uploaderType := u.uploader.GetUploaderType()
+	if config.SkipHashCheck || (config.MD5 == "" && (info.ETag == "" || (uploaderType != Local && uploaderType != QiNiu))) {
+		return nil
+	}
+
+	h := md5.New()
+	etag := newQiNiuEtagWriter()
+	if _, err = io.Copy(io.MultiWriter(h, etag), file); err != nil {
+		return errors.New("checksum file " + tmpPath + ", err: " + err.Error())
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	switch {
+	case config.MD5 != "":
+		if sum != config.MD5 {
+			return fmt.Errorf("%w: md5 %s, expect %s", ChecksumMismatchErr, sum, config.MD5)
+		}
+	case uploaderType == QiNiu:
+		// 新版 hash 无法在本地复现，只校验大小
+		if isQiNiuEtagV1(info.ETag, info.Size) && info.ETag != etag.Sum() {
+			return fmt.Errorf("%w: hash %s, expect %s", ChecksumMismatchErr, etag.Sum(), info.ETag)
+		}
+	default:
+		// 本地存储的 ETag 为上传时记录的 MD5，没有记录时格式不一致，verifyETag 会跳过
+		return verifyETag(info.ETag, sum)
+	}
+
+	return nil
+}
+
+// loadDownloadCheckpoint 读取断点记录，与当前对象不一致或临时文件不存在时返回 expect
+func loadDownloadCheckpoint(cpPath, tmpPath string, expect downloadCheckpoint) *downloadCheckpoint {
+	data, err := os.ReadFile(cpPath)
+	if err != nil {
+		return &expect
+	}
+
+	var cp downloadCheckpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return &expect
+	}
+
+	stat, err := os.Stat(tmpPath)
+	if err != nil || stat.Size() != expect.Size {
+		return &expect
+	}
+
+	if cp.Path != expect.Path || cp.VersionID != expect.VersionID || cp.Size != expect.Size || cp.ETag != expect.ETag ||
+		!cp.LastModified.Equal(expect.LastModified) || cp.PartSize != expect.PartSize || len(cp.Completed) != len(expect.Completed) {
+		return &expect
+	}
+
+	return &cp
+}
+
+func (cp *downloadCheckpoint) save(cpPath string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(cpPath, data, 0666); err != nil {
+		return errors.New("write checkpoint " + cpPath + ", err: " + err.Error())
+	}
+
+	return nil
+}
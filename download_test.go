@@ -0,0 +1,65 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	uploader := NewFileUploader().RegisterUploader(local)
+
+	content := make([]byte, 100)
+	_, _ = rand.Read(content)
+	res, err := uploader.UploadSource(ctx, newSource(t, "a.bin", content), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "a.bin")
+	config := DownloadConfig{PartSize: 7, Workers: 3}
+
+	if err = uploader.DownloadFile(ctx, res.Path, filePath, config); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filePath); !bytes.Equal(got, content) {
+		t.Fatal("downloaded content mismatch")
+	}
+	if _, err = os.Stat(filePath + ".checkpoint"); !os.IsNotExist(err) {
+		t.Fatalf("checkpoint should be removed, got %v", err)
+	}
+
+	// 断点记录中已完成的分片不会重新下载，内容损坏时校验失败
+	info, _ := uploader.StatObject(ctx, res.Path)
+	cp := downloadCheckpoint{
+		Path:         res.Path,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		PartSize:     config.PartSize,
+		Completed:    make([]bool, 15),
+	}
+	cp.Completed[0] = true
+	_ = cp.save(filePath + ".checkpoint")
+	_ = os.WriteFile(filePath+".download", make([]byte, 100), 0666)
+
+	if err = uploader.DownloadFile(ctx, res.Path, filePath, config); !errors.Is(err, ChecksumMismatchErr) {
+		t.Fatalf("expected ChecksumMismatchErr, got %v", err)
+	}
+	if _, err = os.Stat(filePath + ".download"); !os.IsNotExist(err) {
+		t.Fatalf("corrupted temp file should be removed, got %v", err)
+	}
+
+	if err = uploader.DownloadFile(ctx, res.Path, filePath, config); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filePath); !bytes.Equal(got, content) {
+		t.Fatal("downloaded content mismatch after retry")
+	}
+}
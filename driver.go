@@ -8,3 +8,25 @@ const (
 	QiNiu   = "QiNiu"
 	HuaWei  = "OBS"
 )
+
+// Capabilities 驱动支持的功能，不支持的操作返回 UnsupportedOptionErr
+type Capabilities struct {
+	// Versioning 多版本，云存储还需在存储桶上开启，本地驱动需配置 Versioning
+	Versioning bool
+	// Tagging 对象标签
+	Tagging bool
+	// ACL 对象级访问权限
+	ACL bool
+}
+
+var (
+	_ IUpload = (*UploaderLocal)(nil)
+	_ IUpload = (*UploaderMinio)(nil)
+	_ IUpload = (*UploaderOss)(nil)
+	_ IUpload = (*UploaderCos)(nil)
+	_ IUpload = (*UploaderQiNiu)(nil)
+	_ IUpload = (*UploaderObs)(nil)
+
+	_ IUpload = (*EncryptedUploader)(nil)
+	_ IUpload = (*CompressedUploader)(nil)
+)
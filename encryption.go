@@ -0,0 +1,667 @@
+package file_storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/qiuyier/file-storage/pkg/util"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"sync"
+)
+
+const (
+	// encryptionMagic 加密对象的文件头标识
+	encryptionMagic = "FSE1"
+	// EncryptionAlgorithm 写入对象元数据的加密算法标识
+	EncryptionAlgorithm = "AES256-GCM-CHUNKED"
+	// defaultEncryptChunkSize 明文分块大小，每块单独加密并附带 16 字节认证标签
+	defaultEncryptChunkSize = 64 * 1024
+	// maxEncryptChunkSize 解密时允许的最大分块，防止损坏的文件头导致申请过大内存
+	maxEncryptChunkSize = 64 * 1024 * 1024
+	// noncePrefixSize 随机 nonce 前缀长度，剩余 5 字节为分块序号和末块标识
+	noncePrefixSize = 7
+	// dataKeySize AES-256 数据密钥长度
+	dataKeySize = 32
+	// gcmTagSize GCM 认证标签长度
+	gcmTagSize = 16
+)
+
+// 加密对象的元数据，记录算法、主密钥 ID 及原始 Content-Type、Content-Encoding、长度
+const (
+	MetaEncryption      = "fs-encryption"
+	MetaKeyID           = "fs-key-id"
+	MetaContentType     = "fs-content-type"
+	MetaContentEncoding = "fs-content-encoding"
+	MetaPlaintextSize   = "fs-plaintext-size"
+)
+
+// KeyProvider 数据密钥提供方，用于信封加密：每个对象使用独立的数据密钥，数据密钥由主密钥加密后随对象保存
+type KeyProvider interface {
+	// GenerateDataKey 生成数据密钥，返回明文密钥、加密后的密钥及主密钥 ID
+	GenerateDataKey(ctx context.Context) (plaintext, encrypted []byte, keyID string, err error)
+	// DecryptDataKey 使用 keyID 对应的主密钥解密数据密钥
+	DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error)
+}
+
+// LocalKeyProvider 使用本地保存的主密钥加密数据密钥，保留旧主密钥即可解密轮换前上传的对象
+type LocalKeyProvider struct {
+	keys         map[string]cipher.AEAD
+	currentKeyID string
+}
+
+// NewLocalKeyProvider keys 为主密钥 ID 到 32 字节主密钥的映射，currentKeyID 为加密新对象使用的主密钥
+func NewLocalKeyProvider(keys map[string][]byte, currentKeyID string) (provider *LocalKeyProvider, err error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, errors.New("master key " + currentKeyID + " not found")
+	}
+
+	provider = &LocalKeyProvider{
+		keys:         make(map[string]cipher.AEAD, len(keys)),
+		currentKeyID: currentKeyID,
+	}
+
+	for id, key := range keys {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key %s should be %d bytes", id, dataKeySize)
+		}
+
+		provider.keys[id], err = newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+func (p *LocalKeyProvider) GenerateDataKey(ctx context.Context) (plaintext, encrypted []byte, keyID string, err error) {
+	plaintext = make([]byte, dataKeySize)
+	if _, err = rand.Read(plaintext); err != nil {
+		return nil, nil, "", err
+	}
+
+	aead := p.keys[p.currentKeyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, "", err
+	}
+
+	// 加密结果为 nonce + 密文，主密钥 ID 作为附加数据防止替换
+	encrypted = aead.Seal(nonce, nonce, plaintext, []byte(p.currentKeyID))
+
+	return plaintext, encrypted, p.currentKeyID, nil
+}
+
+func (p *LocalKeyProvider) DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: master key %s not found", DecryptFailedErr, keyID)
+	}
+
+	if len(encrypted) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid data key", DecryptFailedErr)
+	}
+
+	plaintext, err := aead.Open(nil, encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", DecryptFailedErr, err)
+	}
+
+	return plaintext, nil
+}
+
+// EncryptedUploader 客户端加密包装，上传前使用 AES-256-GCM 分块加密，GetObject 时透明解密，可包装任意驱动
+//
+// 对象格式：文件头（标识、分块大小、nonce 前缀、主密钥 ID、加密后的数据密钥）+ 若干加密分块，
+// 每个分块的 nonce 包含分块序号和末块标识，文件头作为附加数据，可发现分块被替换、重排或截断。
+// 上传结果中的校验值为密文的校验值。
+type EncryptedUploader struct {
+	IUpload
+	keys KeyProvider
+}
+
+func NewEncryptedUploader(uploader IUpload, keys KeyProvider) *EncryptedUploader {
+	return &EncryptedUploader{
+		IUpload: uploader,
+		keys:    keys,
+	}
+}
+
+func (u *EncryptedUploader) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	file, opts, err = u.encrypt(ctx, file, opts)
+	if err != nil {
+		return res, err
+	}
+
+	return u.IUpload.Upload(ctx, file, randomly, opts...)
+}
+
+func (u *EncryptedUploader) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	file, opts, err = u.encrypt(ctx, file, opts)
+	if err != nil {
+		return res, err
+	}
+
+	return u.IUpload.MultipartUpload(ctx, file, randomly, chunkSize, opts...)
+}
+
+// GetObject 下载并解密对象，数据被篡改时读取返回 DecryptFailedErr
+// 指定范围时从头解密后截取，范围是明文的位置
+func (u *EncryptedUploader) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	rc, err := u.IUpload.GetObject(ctx, path, withoutRange(opts)...)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newDecryptReader(ctx, rc, u.keys)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	return rangeReadCloser(r, newObjectOptions(opts...))
+}
+
+// StatObject 返回加密前的长度、Content-Type 及 Content-Encoding
+// 加密数据流时无法预知长度，没有记录时读取文件头按密文长度推算
+func (u *EncryptedUploader) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	info, err := u.IUpload.StatObject(ctx, path, opts...)
+	if err != nil || info.Metadata[MetaEncryption] == "" {
+		return info, err
+	}
+
+	info.ContentType = info.Metadata[MetaContentType]
+	info.ContentEncoding = info.Metadata[MetaContentEncoding]
+
+	if size, err := strconv.ParseInt(info.Metadata[MetaPlaintextSize], 10, 64); err == nil {
+		info.Size = size
+		return info, nil
+	}
+
+	info.Size, err = u.plaintextSize(ctx, path, info, opts)
+
+	return info, err
+}
+
+// plaintextSize 读取文件头中的分块大小及数据密钥长度，由密文长度推算明文长度
+func (u *EncryptedUploader) plaintextSize(ctx context.Context, path string, info ObjectInfo, opts []ObjectOption) (int64, error) {
+	keyIDLen := int64(len(info.Metadata[MetaKeyID]))
+	fixedLen := int64(4 + 4 + noncePrefixSize + 2)
+
+	rc, err := u.IUpload.GetObject(ctx, path, append(opts[:len(opts):len(opts)], WithRange(0, fixedLen+keyIDLen+2))...)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	head := make([]byte, fixedLen+keyIDLen+2)
+	if _, err = io.ReadFull(rc, head); err != nil {
+		return 0, fmt.Errorf("%w: read header, %v", DecryptFailedErr, err)
+	}
+	if string(head[:4]) != encryptionMagic {
+		return 0, fmt.Errorf("%w: object is not encrypted", DecryptFailedErr)
+	}
+
+	chunkSize := int64(binary.BigEndian.Uint32(head[4:8]))
+	headerSize := fixedLen + keyIDLen + 2 + int64(binary.BigEndian.Uint16(head[fixedLen+keyIDLen:]))
+	if chunkSize == 0 || info.Size < headerSize+gcmTagSize {
+		return 0, fmt.Errorf("%w: invalid header", DecryptFailedErr)
+	}
+
+	// 每个分块为分块大小加认证标签，末块可能不满
+	body := info.Size - headerSize
+
+	return body - (body+chunkSize+gcmTagSize-1)/(chunkSize+gcmTagSize)*gcmTagSize, nil
+}
+
+// encrypt 生成数据密钥并把数据源替换为加密后的数据源，原始 Content-Type、Content-Encoding、长度保存在元数据中
+func (u *EncryptedUploader) encrypt(ctx context.Context, file Source, opts []UploadOption) (Source, []UploadOption, error) {
+	plaintext, encrypted, keyID, err := u.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, nil, errors.New("generate data key, err: " + err.Error())
+	}
+
+	aead, err := newGCM(plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header, err := encryptionHeader(defaultEncryptChunkSize, keyID, encrypted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o := newUploadOptions(opts...)
+
+	contentType := o.ContentType
+	if contentType == "" {
+		fd, err := file.Open()
+		if err != nil {
+			return nil, nil, errors.New("open file " + file.Name() + ", err: " + err.Error())
+		}
+		contentType = util.SniffContentType(fd, file.Name())
+		_ = fd.Close()
+	}
+
+	var encryptedSource Source
+	if file.Size() < 0 {
+		// 长度未知的数据流只能顺序加密，加密结果同样是长度未知的数据流
+		fd, err := file.Open()
+		if err != nil {
+			return nil, nil, errors.New("open file " + file.Name() + ", err: " + err.Error())
+		}
+		encryptedSource = NewStreamSource(file.Name(), newEncryptReader(fd, aead, header, defaultEncryptChunkSize))
+	} else {
+		size := encryptedSize(int64(len(header)), file.Size(), defaultEncryptChunkSize)
+
+		encryptedSource = NewSource(file.Name(), size, func() (multipart.File, error) {
+			fd, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+
+			return newEncryptedFile(fd, aead, header, defaultEncryptChunkSize, file.Size()), nil
+		})
+	}
+
+	metadata := map[string]string{
+		MetaEncryption:  EncryptionAlgorithm,
+		MetaKeyID:       keyID,
+		MetaContentType: contentType,
+	}
+
+	// 密文不能再声明 Content-Encoding，否则客户端会尝试按压缩格式解码
+	if o.ContentEncoding != "" {
+		metadata[MetaContentEncoding] = o.ContentEncoding
+	}
+	if file.Size() >= 0 {
+		metadata[MetaPlaintextSize] = strconv.FormatInt(file.Size(), 10)
+	}
+
+	opts = append(opts[:len(opts):len(opts)],
+		WithContentType("application/octet-stream"),
+		WithContentEncoding(""),
+		WithMetadata(metadata),
+	)
+
+	return encryptedSource, opts, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptionHeader 文件头：标识(4) + 分块大小(4) + nonce 前缀(7) + 主密钥 ID 长度(2) + 主密钥 ID + 数据密钥长度(2) + 加密后的数据密钥
+func encryptionHeader(chunkSize int, keyID string, encryptedKey []byte) ([]byte, error) {
+	if len(keyID) > 0xffff || len(encryptedKey) > 0xffff {
+		return nil, errors.New("key id or data key too long")
+	}
+
+	header := make([]byte, 0, 4+4+noncePrefixSize+2+len(keyID)+2+len(encryptedKey))
+	header = append(header, encryptionMagic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(chunkSize))
+
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header = append(header, prefix...)
+
+	header = binary.BigEndian.AppendUint16(header, uint16(len(keyID)))
+	header = append(header, keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(encryptedKey)))
+	header = append(header, encryptedKey...)
+
+	return header, nil
+}
+
+// chunkNonce nonce 前缀 + 分块序号 + 末块标识
+func chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+
+	return append(nonce, 0)
+}
+
+// encryptedChunks 分块数量，空文件也有一个空的末块
+func encryptedChunks(size, chunkSize int64) int64 {
+	return max(1, (size+chunkSize-1)/chunkSize)
+}
+
+// encryptedSize 加密后的长度，每个分块增加 16 字节认证标签
+func encryptedSize(headerSize, size, chunkSize int64) int64 {
+	return headerSize + size + encryptedChunks(size, chunkSize)*gcmTagSize
+}
+
+// encryptedFile 按需加密的只读文件，支持随机读取，驱动分片上传时可并发调用 ReadAt
+type encryptedFile struct {
+	src       multipart.File
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int64
+	plainSize int64
+	size      int64
+	chunks    int64
+	offset    int64
+
+	mu         sync.Mutex
+	cacheIndex int64
+	cache      []byte
+}
+
+func newEncryptedFile(src multipart.File, aead cipher.AEAD, header []byte, chunkSize int, plainSize int64) *encryptedFile {
+	return &encryptedFile{
+		src:        src,
+		aead:       aead,
+		header:     header,
+		prefix:     header[8 : 8+noncePrefixSize],
+		chunkSize:  int64(chunkSize),
+		plainSize:  plainSize,
+		size:       encryptedSize(int64(len(header)), plainSize, int64(chunkSize)),
+		chunks:     encryptedChunks(plainSize, int64(chunkSize)),
+		cacheIndex: -1,
+	}
+}
+
+func (f *encryptedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	headerSize := int64(len(f.header))
+	sealedSize := f.chunkSize + gcmTagSize
+
+	for n < len(p) && off < f.size {
+		var m int
+		if off < headerSize {
+			m = copy(p[n:], f.header[off:])
+		} else {
+			index := (off - headerSize) / sealedSize
+
+			chunk, err := f.chunk(index)
+			if err != nil {
+				return n, err
+			}
+
+			m = copy(p[n:], chunk[off-headerSize-index*sealedSize:])
+		}
+
+		n += m
+		off += int64(m)
+	}
+
+	if n < len(p) {
+		err = io.EOF
+	}
+
+	return
+}
+
+// chunk 加密第 index 个分块，缓存最近一次的结果以减少顺序读取时的重复计算
+func (f *encryptedFile) chunk(index int64) ([]byte, error) {
+	if index == f.cacheIndex {
+		return f.cache, nil
+	}
+
+	start := index * f.chunkSize
+	plain := make([]byte, min(f.chunkSize, f.plainSize-start))
+	if n, err := f.src.ReadAt(plain, start); n < len(plain) {
+		if err == nil || errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	nonce := chunkNonce(f.prefix, uint32(index), index == f.chunks-1)
+	f.cache = f.aead.Seal(f.cache[:0], nonce, plain, f.header)
+	f.cacheIndex = index
+
+	return f.cache, nil
+}
+
+func (f *encryptedFile) Read(p []byte) (n int, err error) {
+	n, err = f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	return
+}
+
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+
+	return offset, nil
+}
+
+func (f *encryptedFile) Close() error {
+	return f.src.Close()
+}
+
+// encryptReader 顺序加密长度未知的数据流，多读取一个字节判断当前分块是否为末块
+type encryptReader struct {
+	src       *bufio.Reader
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int
+	index     uint32
+	plain     []byte
+	sealed    []byte
+	buf       []byte
+	done      bool
+}
+
+func newEncryptReader(src io.Reader, aead cipher.AEAD, header []byte, chunkSize int) *encryptReader {
+	return &encryptReader{
+		src:       bufio.NewReader(src),
+		aead:      aead,
+		header:    header,
+		prefix:    header[8 : 8+noncePrefixSize],
+		chunkSize: chunkSize,
+		plain:     make([]byte, chunkSize),
+		buf:       header,
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+// seal 读取并加密下一个分块，空数据流也会生成一个空的末块
+func (r *encryptReader) seal() error {
+	n, err := io.ReadFull(r.src, r.plain)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last := err != nil
+	if !last {
+		if _, err = r.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.sealed = r.aead.Seal(r.sealed[:0], chunkNonce(r.prefix, r.index, last), r.plain[:n], r.header)
+	r.buf = r.sealed
+	r.index++
+	r.done = last
+
+	return nil
+}
+
+// decryptReader 顺序解密对象内容，读取到最后一个分块且校验通过才返回 io.EOF
+type decryptReader struct {
+	rc     io.ReadCloser
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	index  uint32
+	buf    []byte
+	plain  []byte
+	done   bool
+}
+
+func newDecryptReader(ctx context.Context, rc io.ReadCloser, keys KeyProvider) (*decryptReader, error) {
+	r := bufio.NewReader(rc)
+
+	fixed := make([]byte, 4+4+noncePrefixSize+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("%w: read header, %v", DecryptFailedErr, err)
+	}
+
+	if string(fixed[:4]) != encryptionMagic {
+		return nil, fmt.Errorf("%w: object is not encrypted", DecryptFailedErr)
+	}
+
+	chunkSize := binary.BigEndian.Uint32(fixed[4:8])
+	if chunkSize == 0 || chunkSize > maxEncryptChunkSize {
+		return nil, fmt.Errorf("%w: invalid chunk size %d", DecryptFailedErr, chunkSize)
+	}
+
+	keyID := make([]byte, binary.BigEndian.Uint16(fixed[4+4+noncePrefixSize:]))
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, fmt.Errorf("%w: read header, %v", DecryptFailedErr, err)
+	}
+
+	keyLen := make([]byte, 2)
+	if _, err := io.ReadFull(r, keyLen); err != nil {
+		return nil, fmt.Errorf("%w: read header, %v", DecryptFailedErr, err)
+	}
+
+	encryptedKey := make([]byte, binary.BigEndian.Uint16(keyLen))
+	if _, err := io.ReadFull(r, encryptedKey); err != nil {
+		return nil, fmt.Errorf("%w: read header, %v", DecryptFailedErr, err)
+	}
+
+	dataKey, err := keys.DecryptDataKey(ctx, string(keyID), encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(fixed)+len(keyID)+len(keyLen)+len(encryptedKey))
+	header = append(header, fixed...)
+	header = append(header, keyID...)
+	header = append(header, keyLen...)
+	header = append(header, encryptedKey...)
+
+	return &decryptReader{
+		rc:     rc,
+		r:      r,
+		aead:   aead,
+		header: header,
+		prefix: header[8 : 8+noncePrefixSize],
+		buf:    make([]byte, int(chunkSize)+gcmTagSize),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+
+	return n, nil
+}
+
+// next 读取并解密下一个分块，分块不满或其后没有数据时视为末块
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.buf)
+
+	last := false
+	switch {
+	case err == nil:
+		if _, err = d.r.Peek(1); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			last = true
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case errors.Is(err, io.EOF):
+		// 未读到末块数据就结束，对象被截断
+		return fmt.Errorf("%w: unexpected end of object", DecryptFailedErr)
+	default:
+		return err
+	}
+
+	plain, err := d.aead.Open(d.buf[:0], chunkNonce(d.prefix, d.index, last), d.buf[:n], d.header)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d, %v", DecryptFailedErr, d.index, err)
+	}
+
+	d.plain = plain
+	d.index++
+	d.done = last
+
+	return nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.rc.Close()
+}
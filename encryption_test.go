@@ -0,0 +1,91 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestEncryptedUploader(t *testing.T) {
+	ctx := context.Background()
+
+	keys, err := NewLocalKeyProvider(map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	uploader := NewEncryptedUploader(local, keys)
+
+	for _, size := range []int{0, 10, defaultEncryptChunkSize, 3*defaultEncryptChunkSize + 7} {
+		content := make([]byte, size)
+		_, _ = rand.Read(content)
+
+		res, err := uploader.Upload(ctx, newSource(t, "a.bin", content), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored, _ := os.ReadFile(res.Path)
+		if bytes.Contains(stored, content) && size > 0 {
+			t.Fatalf("size %d: content stored in plaintext", size)
+		}
+
+		meta, _ := local.readMeta(res.Path)
+		if meta.Metadata[MetaKeyID] != "k1" || meta.ContentType != "application/octet-stream" {
+			t.Fatalf("size %d: unexpected meta %+v", size, meta)
+		}
+
+		rc, err := uploader.GetObject(ctx, res.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("size %d: round trip failed, err %v", size, err)
+		}
+
+		// 长度未知的数据流顺序加密
+		streamRes, err := uploader.Upload(ctx, NewStreamSource("a.bin", bytes.NewReader(content)), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamStored, _ := os.ReadFile(streamRes.Path)
+		if len(streamStored) != len(stored) {
+			t.Fatalf("size %d: stream encrypted size %d, expect %d", size, len(streamStored), len(stored))
+		}
+
+		// 记录的长度及由文件头推算的长度
+		for _, p := range []string{res.Path, streamRes.Path} {
+			info, err := uploader.StatObject(ctx, p)
+			if err != nil || info.Size != int64(size) {
+				t.Fatalf("size %d: unexpected stat %+v, %v", size, info, err)
+			}
+		}
+		rc, err = uploader.GetObject(ctx, streamRes.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("size %d: stream round trip failed, err %v", size, err)
+		}
+
+		// 截断最后一个分块
+		_ = os.WriteFile(res.Path, stored[:len(stored)-1], 0666)
+		rc, err = uploader.GetObject(ctx, res.Path)
+		if err == nil {
+			_, err = io.ReadAll(rc)
+			_ = rc.Close()
+		}
+		if !errors.Is(err, DecryptFailedErr) {
+			t.Fatalf("size %d: expected DecryptFailedErr, got %v", size, err)
+		}
+	}
+}
@@ -3,5 +3,21 @@ package file_storage
 import "errors"
 
 var (
-	NotDirErr = errors.New(`"dirPath\" should be a directory path`)
+	NotDirErr            = errors.New(`"dirPath\" should be a directory path`)
+	ObjectExistsErr      = errors.New("object already exists")
+	UnsupportedOptionErr = errors.New("option not supported by driver")
+	PathEscapeErr        = errors.New("path escapes local root")
+	ReservedPathErr      = errors.New("path is reserved by driver")
+	ChecksumMismatchErr  = errors.New("checksum mismatch")
+	ObjectNotFoundErr    = errors.New("object not found")
+	DecryptFailedErr     = errors.New("decrypt failed")
+	AccessDeniedErr      = errors.New("access denied")
+	EmptyPrefixErr       = errors.New("prefix must not be empty")
+	TrashDisabledErr     = errors.New("trash is not enabled")
+	NotInTrashErr        = errors.New("path is not in trash")
+	InvalidTagErr        = errors.New("invalid object tag")
+	NoUploadFileErr      = errors.New("no file in request")
+	TooManyFilesErr      = errors.New("too many files in request")
+	InvalidRangeErr      = errors.New("invalid range")
+	NotDeletedErr        = errors.New("object not in deleted list")
 )
@@ -0,0 +1,130 @@
+package file_storage
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localFileServer 本地驱动的文件服务，不提供目录列表
+type localFileServer struct {
+	u *UploaderLocal
+}
+
+// FileServer 返回提供本地文件访问的 http.Handler，按 PublicPath 匹配请求路径，
+// 支持 Range、ETag、Last-Modified，私有对象需携带有效的签名
+func (u *UploaderLocal) FileServer() http.Handler {
+	return &localFileServer{u: u}
+}
+
+func (s *localFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, ok := s.key(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	filePath, err := s.u.resolveObject(filepath.Join(s.u.root, filepath.FromSlash(key)))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	meta, err := s.u.readMeta(filePath)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	acl := meta.ACL
+	if acl == ACLDefault {
+		acl = s.u.acl
+	}
+	if acl == ACLPrivate && !s.u.verifySignature(key, r.URL.Query()) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	header := w.Header()
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if meta.CacheControl != "" {
+		header.Set("Cache-Control", meta.CacheControl)
+	}
+	if meta.ContentDisposition != "" {
+		header.Set("Content-Disposition", meta.ContentDisposition)
+	}
+	if meta.ContentEncoding != "" {
+		header.Set("Content-Encoding", meta.ContentEncoding)
+	}
+	header.Set("ETag", `"`+localETag(meta, stat)+`"`)
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent 处理 Range、If-None-Match、If-Modified-Since 及 HEAD 请求
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), file)
+}
+
+// key 去掉 PublicPath 前缀得到相对 LocalPath 的路径，拒绝目录及以 . 开头的路径段
+// 元数据、历史版本、回收站等内部目录均以 . 开头，不对外提供访问
+func (s *localFileServer) key(urlPath string) (string, bool) {
+	if strings.HasSuffix(urlPath, "/") {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if s.u.publicPath != "" {
+		if !strings.HasPrefix(key, s.u.publicPath+"/") {
+			return "", false
+		}
+		key = strings.TrimPrefix(key, s.u.publicPath+"/")
+	}
+
+	if key == "" {
+		return "", false
+	}
+
+	for _, v := range strings.Split(key, "/") {
+		if strings.HasPrefix(v, ".") {
+			return "", false
+		}
+	}
+
+	return key, true
+}
+
+func (s *localFileServer) error(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ObjectNotFoundErr) || errors.Is(err, PathEscapeErr) || errors.Is(err, ReservedPathErr) || os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,88 @@
+package file_storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalFileServer(t *testing.T) {
+	root := t.TempDir()
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root, PublicPath: "/static", SignKey: "secret"})
+	ctx := context.Background()
+
+	public, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("hello world")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := uploader.Upload(ctx, newSource(t, "b.txt", []byte("secret")), false, WithACL(ACLPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 回收站中的对象不可访问
+	trash := NewFileUploader().RegisterUploader(uploader).SetTrash(TrashConfig{Prefix: filepath.Join(root, ".trash")})
+	deleted, err := uploader.Upload(ctx, newSource(t, "c.txt", []byte("deleted")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = trash.DeleteObjects(ctx, []string{deleted.Path}); err != nil {
+		t.Fatal(err)
+	}
+	trashPath, _ := filepath.Rel(root, trash.trash.key(time.Now().Format(time.DateOnly), deleted.Path))
+	if !exists(filepath.Join(root, trashPath)) {
+		t.Fatal("object was not moved to trash")
+	}
+
+	server := httptest.NewServer(uploader.FileServer())
+	defer server.Close()
+
+	get := func(rawUrl string, header map[string]string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+rawUrl, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := get(public.FileUrl, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/plain; charset=utf-8" || resp.Header.Get("Last-Modified") == "" {
+		t.Fatalf("unexpected response %d %v", resp.StatusCode, resp.Header)
+	}
+	etag := resp.Header.Get("ETag")
+
+	if resp = get(public.FileUrl, map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", resp.StatusCode)
+	}
+	if resp = get(public.FileUrl, map[string]string{"Range": "bytes=0-4"}); resp.StatusCode != http.StatusPartialContent || resp.ContentLength != 5 {
+		t.Fatalf("expected partial content, got %d %d", resp.StatusCode, resp.ContentLength)
+	}
+
+	if resp = get(private.FileUrl, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected signed url to be served, got %d", resp.StatusCode)
+	}
+	u, _ := url.Parse(private.FileUrl)
+	if resp = get(u.Path, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 without signature, got %d", resp.StatusCode)
+	}
+	if resp = get(u.Path+"?expires=1&signature="+localSignature("secret", u.Path[len("/static/"):], 1), nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for expired signature, got %d", resp.StatusCode)
+	}
+
+	pu, _ := url.Parse(public.FileUrl)
+	for _, p := range []string{"/static/", pu.Path[:len(pu.Path)-len("/a.txt")], "/static/.meta/" + pu.Path[len("/static/"):] + ".json", "/static/.versions/a.txt", "/static/" + filepath.ToSlash(trashPath), "/static/../a.txt", "/a.txt"} {
+		if resp = get(p, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", p, resp.StatusCode)
+		}
+	}
+}
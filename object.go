@@ -0,0 +1,195 @@
+package file_storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectInfo 对象属性
+type ObjectInfo struct {
+	Path            string
+	Size            int64
+	ETag            string
+	ContentType     string
+	ContentEncoding string
+	LastModified    time.Time
+	// VersionID 对象版本，未开启多版本时为空
+	VersionID string
+	// Metadata 用户自定义元数据，key 为去掉厂商前缀后的小写形式
+	Metadata map[string]string
+}
+
+// ObjectOption 读取、移动对象的可选参数
+type ObjectOption func(*ObjectOptions)
+
+type ObjectOptions struct {
+	// VersionID 读取指定版本，为空时读取最新版本
+	VersionID string
+	// Offset 读取的起始位置，仅 GetObject 有效
+	Offset int64
+	// Length 读取的长度，小于等于 0 时读取到对象末尾，仅 GetObject 有效
+	Length int64
+	// NoOverwrite 目标已存在时返回 ObjectExistsErr，仅 MoveObject 有效
+	NoOverwrite bool
+}
+
+// WithVersionID 读取对象的指定版本
+func WithVersionID(versionID string) ObjectOption {
+	return func(o *ObjectOptions) {
+		o.VersionID = versionID
+	}
+}
+
+// WithRange 只读取 [offset, offset+length) 范围的内容，length 小于等于 0 时读取到对象末尾
+// 起始位置超出对象长度时 GetObject 返回 InvalidRangeErr
+// 加密、压缩包装写入的对象需从头解码后截取，耗时与 offset 成正比，DownloadFile 对这类对象改为顺序下载
+func WithRange(offset, length int64) ObjectOption {
+	return func(o *ObjectOptions) {
+		o.Offset = offset
+		o.Length = length
+	}
+}
+
+// WithNoOverwrite 移动对象时不覆盖已存在的目标
+// OSS、COS、七牛及本地驱动由服务端或文件系统原子保证，OBS、Minio 为先检查再复制，并发时仍可能覆盖
+func WithNoOverwrite() ObjectOption {
+	return func(o *ObjectOptions) {
+		o.NoOverwrite = true
+	}
+}
+
+func newObjectOptions(opts ...ObjectOption) *ObjectOptions {
+	o := &ObjectOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// hasRange 是否只读取部分内容
+func (o *ObjectOptions) hasRange() bool {
+	return o.Offset != 0 || o.Length > 0
+}
+
+// rangeHeader 转换为 HTTP Range 请求头，未指定范围时返回空
+func (o *ObjectOptions) rangeHeader() (string, error) {
+	if !o.hasRange() {
+		return "", nil
+	}
+	if o.Offset < 0 {
+		return "", fmt.Errorf("%w: offset %d", InvalidRangeErr, o.Offset)
+	}
+
+	if o.Length <= 0 {
+		return fmt.Sprintf("bytes=%d-", o.Offset), nil
+	}
+
+	return fmt.Sprintf("bytes=%d-%d", o.Offset, o.Offset+o.Length-1), nil
+}
+
+// withoutRange 读取完整对象，供需要先解码再截取范围的包装使用
+func withoutRange(opts []ObjectOption) []ObjectOption {
+	return append(opts[:len(opts):len(opts)], WithRange(0, 0))
+}
+
+// rangeReadCloser 从完整内容中截取范围，跳过 offset 之前的内容
+func rangeReadCloser(rc io.ReadCloser, o *ObjectOptions) (io.ReadCloser, error) {
+	if !o.hasRange() {
+		return rc, nil
+	}
+	if o.Offset < 0 {
+		_ = rc.Close()
+		return nil, fmt.Errorf("%w: offset %d", InvalidRangeErr, o.Offset)
+	}
+
+	r := bufio.NewReader(rc)
+	if _, err := io.CopyN(io.Discard, r, o.Offset); err != nil {
+		_ = rc.Close()
+		if err == io.EOF {
+			return nil, InvalidRangeErr
+		}
+		return nil, err
+	}
+
+	// 起始位置等于对象长度时同样视为超出范围
+	if _, err := r.Peek(1); err != nil {
+		_ = rc.Close()
+		if err == io.EOF {
+			return nil, InvalidRangeErr
+		}
+		return nil, err
+	}
+
+	var reader io.Reader = r
+	if o.Length > 0 {
+		reader = io.LimitReader(r, o.Length)
+	}
+
+	return readCloser{Reader: reader, Closer: rc}, nil
+}
+
+// readCloser 组合读取和关闭
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// headerObjectInfo 从 HEAD 响应头解析对象属性，metaPrefix 为厂商自定义元数据前缀，如 x-oss-meta-
+func headerObjectInfo(path string, header http.Header, metaPrefix string) ObjectInfo {
+	info := ObjectInfo{
+		Path:            path,
+		ETag:            strings.Trim(header.Get("ETag"), `"`),
+		ContentType:     header.Get("Content-Type"),
+		ContentEncoding: header.Get("Content-Encoding"),
+		Metadata:        make(map[string]string),
+	}
+
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(header.Get("Last-Modified"))
+	// 版本号响应头与元数据使用相同的厂商前缀，如 x-oss-version-id
+	info.VersionID = header.Get(strings.TrimSuffix(metaPrefix, "meta-") + "version-id")
+
+	for k, v := range header {
+		if key := strings.ToLower(k); strings.HasPrefix(key, metaPrefix) && len(v) > 0 {
+			info.Metadata[strings.TrimPrefix(key, metaPrefix)] = v[0]
+		}
+	}
+
+	return info
+}
+
+// copyPartSize 超过单次复制上限的对象按该大小分片复制
+const copyPartSize = 512 << 20
+
+// copyHeaders 分片复制不会保留源对象的属性，从 HEAD 响应头中取出需要在初始化分片时重新设置的请求头
+func copyHeaders(header http.Header, metaPrefix string) http.Header {
+	h := http.Header{}
+	for k, v := range header {
+		switch key := strings.ToLower(k); key {
+		case "content-type", "content-encoding", "content-disposition", "cache-control", "expires":
+			h[k] = v
+		default:
+			if strings.HasPrefix(key, metaPrefix) {
+				h[k] = v
+			}
+		}
+	}
+
+	return h
+}
+
+// lowerMetadata 统一元数据 key 为去掉前缀后的小写形式
+func lowerMetadata(metadata map[string]string, prefix string) map[string]string {
+	meta := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		meta[strings.TrimPrefix(strings.ToLower(k), prefix)] = v
+	}
+
+	return meta
+}
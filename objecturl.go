@@ -0,0 +1,72 @@
+package file_storage
+
+import (
+	"net/url"
+	"strings"
+)
+
+// URLStyle 未配置自定义域名时对象访问地址的风格
+type URLStyle int
+
+const (
+	// URLStylePath endpoint/bucket/key
+	URLStylePath URLStyle = iota
+	// URLStyleVirtualHosted bucket.endpoint/key
+	URLStyleVirtualHosted
+)
+
+// urlBuilder 拼接对象的公共访问地址，key 按路径段转义
+type urlBuilder struct {
+	// base 带协议头的基础地址，不以 / 结尾，为空时生成以 / 开头的相对地址
+	base string
+}
+
+// newURLBuilder domain 可包含协议头和路径前缀，segments 追加在域名之后
+func newURLBuilder(domain string, secure bool, segments ...string) urlBuilder {
+	base := strings.TrimRight(withScheme(domain, secure), "/")
+	for _, v := range segments {
+		if v = strings.Trim(v, "/"); v != "" {
+			base += "/" + escapePath(v)
+		}
+	}
+
+	return urlBuilder{base: base}
+}
+
+func (b urlBuilder) build(key string) string {
+	return b.base + "/" + escapePath(strings.TrimLeft(key, "/"))
+}
+
+// withScheme 域名未带协议头时按 secure 补全
+func withScheme(domain string, secure bool) string {
+	if domain == "" || strings.HasPrefix(domain, "http://") || strings.HasPrefix(domain, "https://") {
+		return domain
+	}
+
+	if secure {
+		return "https://" + domain
+	}
+
+	return "http://" + domain
+}
+
+// virtualHostedDomain 在 endpoint 的主机名前加上存储桶名，保留 endpoint 的协议头
+func virtualHostedDomain(bucket, endpoint string) string {
+	for _, scheme := range []string{"http://", "https://"} {
+		if strings.HasPrefix(endpoint, scheme) {
+			return scheme + bucket + "." + strings.TrimPrefix(endpoint, scheme)
+		}
+	}
+
+	return bucket + "." + endpoint
+}
+
+// escapePath 按路径段转义，保留分隔符
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, v := range segments {
+		segments[i] = url.PathEscape(v)
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,24 @@
+package file_storage
+
+import "testing"
+
+func TestURLBuilder(t *testing.T) {
+	cases := []struct {
+		builder urlBuilder
+		key     string
+		expect  string
+	}{
+		{newURLBuilder("https://cdn.example.com/", true), "a/b c.txt", "https://cdn.example.com/a/b%20c.txt"},
+		{newURLBuilder("cdn.example.com", false, "bucket"), "/a#1?.txt", "http://cdn.example.com/bucket/a%231%3F.txt"},
+		{newURLBuilder("", true, "/static/"), "a.txt", "/static/a.txt"},
+		{minioURLBuilder(UploaderMinioConfig{EndPoint: "127.0.0.1:9000", BucketName: "b"}), "a.txt", "http://127.0.0.1:9000/b/a.txt"},
+		{minioURLBuilder(UploaderMinioConfig{EndPoint: "s3.example.com", BucketName: "b", UseSSL: true, URLStyle: URLStyleVirtualHosted}), "a.txt", "https://b.s3.example.com/a.txt"},
+		{newURLBuilder(ossDomain(UploaderOssConfig{EndPoint: "http://oss-cn-hangzhou.aliyuncs.com", BucketName: "b"}), true), "a.txt", "http://b.oss-cn-hangzhou.aliyuncs.com/a.txt"},
+	}
+
+	for _, c := range cases {
+		if got := c.builder.build(c.key); got != c.expect {
+			t.Errorf("expected %s, got %s", c.expect, got)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package file_storage
+
+import (
+	"github.com/qiuyier/file-storage/pkg/util"
+	"io"
+)
+
+// UploadOptions 上传选项，各驱动按自身 SDK 映射为对应的请求头
+type UploadOptions struct {
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	// Metadata 用户自定义元数据，key 不需要带厂商前缀
+	Metadata map[string]string
+	// Overwrite 为空时使用驱动配置的策略
+	Overwrite *OverwritePolicy
+	// SSE 服务端加密，为空时使用驱动配置
+	SSE *ServerSideEncryption
+	// Progress 上传进度回调
+	Progress ProgressListener
+	// RateLimiters 上传限速，同时设置多个时全部生效
+	RateLimiters []*RateLimiter
+	// Tags 对象标签
+	Tags map[string]string
+	// ACL 访问权限，为空时使用驱动配置
+	ACL ACL
+}
+
+type UploadOption func(o *UploadOptions)
+
+func WithContentType(contentType string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentType = contentType
+	}
+}
+
+func WithCacheControl(cacheControl string) UploadOption {
+	return func(o *UploadOptions) {
+		o.CacheControl = cacheControl
+	}
+}
+
+func WithContentDisposition(contentDisposition string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentDisposition = contentDisposition
+	}
+}
+
+func WithContentEncoding(contentEncoding string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentEncoding = contentEncoding
+	}
+}
+
+// WithMetadata 设置用户自定义元数据，多次调用会合并
+func WithMetadata(metadata map[string]string) UploadOption {
+	return func(o *UploadOptions) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			o.Metadata[k] = v
+		}
+	}
+}
+
+// WithOverwritePolicy 覆盖驱动配置的同名文件处理策略
+func WithOverwritePolicy(policy OverwritePolicy) UploadOption {
+	return func(o *UploadOptions) {
+		o.Overwrite = &policy
+	}
+}
+
+// WithServerSideEncryption 设置服务端加密，覆盖驱动配置，七牛和本地驱动不支持
+func WithServerSideEncryption(sse ServerSideEncryption) UploadOption {
+	return func(o *UploadOptions) {
+		o.SSE = &sse
+	}
+}
+
+func newUploadOptions(opts ...UploadOption) *UploadOptions {
+	o := &UploadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// resolveContentType 未指定 ContentType 时结合文件头与扩展名探测
+func (o *UploadOptions) resolveContentType(fd io.ReaderAt, fileName string) {
+	if o.ContentType == "" {
+		o.ContentType = util.SniffContentType(fd, fileName)
+	}
+}
+
+// overwritePolicy 返回本次上传生效的同名文件处理策略
+func (o *UploadOptions) overwritePolicy(def OverwritePolicy) OverwritePolicy {
+	if o.Overwrite != nil {
+		return *o.Overwrite
+	}
+
+	return def
+}
+
+// serverSideEncryption 返回本次上传生效的服务端加密配置，为空表示不加密
+func (o *UploadOptions) serverSideEncryption(def *ServerSideEncryption) (*ServerSideEncryption, error) {
+	sse := def
+	if o.SSE != nil {
+		sse = o.SSE
+	}
+
+	if sse == nil {
+		return nil, nil
+	}
+
+	return sse, sse.validate()
+}
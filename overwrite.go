@@ -0,0 +1,57 @@
+package file_storage
+
+import (
+	"context"
+	"github.com/qiuyier/file-storage/pkg/util"
+)
+
+// OverwritePolicy 同名文件处理策略
+// OSS、COS、七牛由服务端，本地驱动由文件系统保证不覆盖已有对象，OBS、Minio 只能先检查再上传，并发时仍可能覆盖
+type OverwritePolicy int
+
+const (
+	// OverwriteAllow 直接覆盖同名文件（默认）
+	OverwriteAllow OverwritePolicy = iota
+	// OverwriteFail 同名文件存在时返回 ObjectExistsErr
+	OverwriteFail
+	// OverwriteRename 同名文件存在时自动追加序号，如 name (1).ext
+	OverwriteRename
+)
+
+// maxRenameAttempts 自动追加序号的最大尝试次数
+const maxRenameAttempts = 1000
+
+type existsFunc func(ctx context.Context, path string) (bool, error)
+
+// resolvePath 根据策略确定最终的上传路径
+func resolvePath(ctx context.Context, exists existsFunc, path string, policy OverwritePolicy) (string, error) {
+	if policy == OverwriteAllow {
+		return path, nil
+	}
+
+	ok, err := exists(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return path, nil
+	}
+
+	if policy == OverwriteFail {
+		return "", ObjectExistsErr
+	}
+
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := util.SuffixName(path, i)
+
+		ok, err = exists(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return candidate, nil
+		}
+	}
+
+	return "", ObjectExistsErr
+}
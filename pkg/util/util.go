@@ -1,17 +1,24 @@
 package util
 
 import (
+	"bytes"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"golang.org/x/text/unicode/norm"
 	"io"
-	"math/rand"
+	"math/big"
 	"mime"
 	"mime/multipart"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -33,20 +40,24 @@ type FileChunk struct {
 	Number int   // Chunk number
 	Offset int64 // Chunk offset
 	Size   int64 // Chunk size.
-	Buf    *strings.Reader
+	Buf    *bytes.Reader
 }
 
-// RandomlyName 生成随机字符串
+// RandomlyName 生成随机字符串，使用 crypto/rand 保证并发下不会因种子相同而重复
 func RandomlyName(length int) string {
 	if length <= 0 {
 		return ""
 	}
 
-	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
 	b := make([]byte, length)
+	max := big.NewInt(int64(len(charset)))
 
 	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic("util: crypto/rand unavailable: " + err.Error())
+		}
+		b[i] = charset[n.Int64()]
 	}
 
 	return string(b)
@@ -95,19 +106,102 @@ func Join(paths ...string) string {
 	return s
 }
 
-func GenName(path, fileName string, randomly bool) string {
-	name := filepath.Base(fileName)
+// GenFileName 生成文件名，randomly 为 true 时使用时间戳加随机串重新命名
+func GenFileName(fileName string, randomly bool) string {
+	name := SanitizeFileName(fileName)
 
 	// 如果设置随机名，则重新命名
 	if randomly {
-		random := RandomlyName(6)
+		random := RandomlyName(16)
 		name = strings.ToLower(strconv.FormatInt(time.Now().UnixNano(), 36) + random)
-		name = fmt.Sprintf("%s%s", name, Ext(fileName))
+		name = fmt.Sprintf("%s%s", name, Ext(SanitizeFileName(fileName)))
+	}
+
+	return name
+}
+
+// maxFileNameLength 大多数文件系统与对象存储允许的单个文件名最大字节数
+const maxFileNameLength = 255
+
+// reservedChars Windows 及对象存储 URL 中的保留字符
+const reservedChars = `<>:"/\|?*`
+
+// windowsReservedNames Windows 设备名，不能作为文件名
+var windowsReservedNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// SanitizeFileName 清理客户端传入的文件名
+// 只保留最后一级路径，统一为 NFC 形式，去除控制字符、格式字符与保留字符，
+// 去掉首尾的点和空格，避开 Windows 设备名并限制长度
+func SanitizeFileName(fileName string) string {
+	// 兼容 Windows 客户端上传的 C:\path\name 形式
+	if p := strings.LastIndexAny(fileName, `/\`); p != -1 {
+		fileName = fileName[p+1:]
+	}
+
+	fileName = norm.NFC.String(fileName)
+
+	var b strings.Builder
+	for _, r := range fileName {
+		if r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || strings.ContainsRune(reservedChars, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	name := strings.TrimFunc(b.String(), func(r rune) bool {
+		return r == '.' || unicode.IsSpace(r)
+	})
+	if name == "" {
+		return "file"
+	}
+
+	ext := Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if _, ok := windowsReservedNames[strings.ToUpper(base)]; ok {
+		base = "_" + base
+	}
+
+	// 超长时截断主文件名，保留扩展名
+	if len(base)+len(ext) > maxFileNameLength {
+		if len(ext) > maxFileNameLength/2 {
+			ext = ""
+		}
+		base = truncateUTF8(base, maxFileNameLength-len(ext))
 	}
 
+	return base + ext
+}
+
+// truncateUTF8 按字节截断字符串，不截断多字节字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// GenName 生成云存储对象键，对象键固定以 / 分隔，与操作系统无关
+func GenName(prefix, fileName string, randomly bool) string {
 	nowDate := time.Now().Format(time.DateOnly)
 
-	return Join(path, nowDate, name)
+	return path.Join(prefix, nowDate, GenFileName(fileName, randomly))
+}
+
+// SuffixName 为文件名追加序号，如 dir/name.png -> dir/name (1).png
+func SuffixName(path string, n int) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	return fmt.Sprintf("%s (%d)%s", base, n, ext)
 }
 
 func GetContentType(ext string) string {
@@ -118,6 +212,76 @@ func GetContentType(ext string) string {
 	}
 }
 
+// SniffLen 内容探测最多读取的字节数
+const SniffLen = 512
+
+// SniffContentType 通过 ReadAt 读取文件前 512 字节探测类型，不影响文件读取位置
+func SniffContentType(r io.ReaderAt, fileName string) string {
+	head := make([]byte, SniffLen)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return GetContentType(Ext(fileName))
+	}
+
+	return DetectContentType(head[:n], fileName)
+}
+
+// DetectContentType 结合文件头与扩展名判断 MIME 类型
+// 文件头能识别出具体类型时以文件头为准，只有探测结果过于宽泛（未知二进制、纯文本、zip 容器）时才采用扩展名
+func DetectContentType(head []byte, fileName string) string {
+	byExt := mime.TypeByExtension(Ext(fileName))
+	if len(head) == 0 {
+		if byExt == "" {
+			return "application/octet-stream"
+		}
+		return byExt
+	}
+
+	sniffed := http.DetectContentType(head)
+	if byExt == "" {
+		return sniffed
+	}
+
+	switch mediaType(sniffed) {
+	case "application/octet-stream":
+		return byExt
+	case "text/plain", "text/xml":
+		if isTextual(mediaType(byExt)) {
+			return byExt
+		}
+	case "application/zip":
+		if isZipBased(mediaType(byExt)) {
+			return byExt
+		}
+	}
+
+	return sniffed
+}
+
+func mediaType(contentType string) string {
+	if p := strings.IndexByte(contentType, ';'); p != -1 {
+		contentType = contentType[:p]
+	}
+	return strings.TrimSpace(strings.ToLower(contentType))
+}
+
+func isTextual(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		strings.HasSuffix(mediaType, "javascript") ||
+		mediaType == "image/svg+xml"
+}
+
+func isZipBased(mediaType string) bool {
+	return mediaType == "application/zip" ||
+		strings.HasSuffix(mediaType, "+zip") ||
+		strings.Contains(mediaType, "openxmlformats") ||
+		strings.Contains(mediaType, "opendocument") ||
+		strings.Contains(mediaType, "java-archive") ||
+		strings.Contains(mediaType, "android.package-archive")
+}
+
 // SplitFileByPartSize 来自oss SplitFileByPartSize，修改用于文件流分片
 func SplitFileByPartSize(fd multipart.File, fileSize, chunkSize int64) ([]FileChunk, error) {
 	if chunkSize <= 0 {
@@ -141,7 +305,7 @@ func SplitFileByPartSize(fd multipart.File, fileSize, chunkSize int64) ([]FileCh
 		if err != nil && err != io.EOF {
 			return nil, errors.New("Error reading file chunk: " + err.Error())
 		}
-		chunk.Buf = strings.NewReader(string(buf))
+		chunk.Buf = bytes.NewReader(buf)
 
 		chunks = append(chunks, chunk)
 	}
@@ -156,7 +320,7 @@ func SplitFileByPartSize(fd multipart.File, fileSize, chunkSize int64) ([]FileCh
 		if err != nil && err != io.EOF {
 			return nil, errors.New("Error reading file chunk: " + err.Error())
 		}
-		chunk.Buf = strings.NewReader(string(buf))
+		chunk.Buf = bytes.NewReader(buf)
 
 		chunks = append(chunks, chunk)
 	}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"mime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRandomlyName(t *testing.T) {
+	seen := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		name := RandomlyName(16)
+		if len(name) != 16 {
+			t.Fatalf("unexpected length %d", len(name))
+		}
+		if _, ok := seen[name]; ok {
+			t.Fatalf("duplicate name %s", name)
+		}
+		seen[name] = struct{}{}
+	}
+}
+
+func TestSuffixName(t *testing.T) {
+	cases := map[string]string{
+		"a/b.png":    "a/b (1).png",
+		"a/b":        "a/b (1)",
+		"a/b.tar.gz": "a/b.tar (1).gz",
+	}
+	for in, want := range cases {
+		if got := SuffixName(in, 1); got != want {
+			t.Errorf("SuffixName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	pdf := []byte("%PDF-1.7\n")
+	zip := []byte("PK\x03\x04\x14\x00\x00\x00")
+	_ = mime.AddExtensionType(".epub", "application/epub+zip")
+
+	cases := []struct {
+		head     []byte
+		fileName string
+		want     string
+	}{
+		{png, "photo", "image/png"},
+		{pdf, "photo.jpg", "application/pdf"},
+		{[]byte(`{"a":1}`), "data.json", "application/json"},
+		{[]byte("hello"), "photo.png", "text/plain; charset=utf-8"},
+		{zip, "book.epub", "application/epub+zip"},
+		{zip, "archive", "application/zip"},
+		{nil, "empty.json", "application/json"},
+		{nil, "empty", "application/octet-stream"},
+	}
+	for _, c := range cases {
+		if got := DetectContentType(c.head, c.fileName); got != c.want {
+			t.Errorf("DetectContentType(%q) = %q, want %q", c.fileName, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	cases := map[string]string{
+		"../../etc/passwd":      "passwd",
+		`C:\Users\a\report.pdf`: "report.pdf",
+		"a\x00b\u202ec.txt":     "abc.txt",
+		"  ..hidden.txt. ":      "hidden.txt",
+		"con.txt":               "_con.txt",
+		"caf\u0065\u0301.png":   "caf\u00e9.png",
+		`what?<is>"this"|*.md`:  "whatisthis.md",
+		"":                      "file",
+		"...":                   "file",
+	}
+	for in, want := range cases {
+		if got := SanitizeFileName(in); got != want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	long := SanitizeFileName(strings.Repeat("文", 200) + ".png")
+	if len(long) > 255 || !strings.HasSuffix(long, ".png") || !utf8.ValidString(long) {
+		t.Errorf("unexpected truncated name %q", long)
+	}
+}
+
+func TestGenName(t *testing.T) {
+	name := GenName("upload", "a.txt", false)
+	if !strings.HasPrefix(name, "upload/") || !strings.HasSuffix(name, "/a.txt") || strings.Contains(name, "\\") {
+		t.Fatalf("unexpected name %s", name)
+	}
+}
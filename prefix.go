@@ -0,0 +1,55 @@
+package file_storage
+
+import (
+	"context"
+	"strings"
+)
+
+// checkPrefix 拒绝空前缀，避免误删整个存储桶
+func checkPrefix(prefix string) error {
+	if strings.Trim(prefix, "/") == "" {
+		return EmptyPrefixErr
+	}
+
+	return nil
+}
+
+// deletePrefix 边遍历边按批次删除，dryRun 时只返回将被删除的对象
+func deletePrefix(ctx context.Context, u IUpload, prefix string, dryRun bool) (res DeleteResult, err error) {
+	if err = checkPrefix(prefix); err != nil {
+		return
+	}
+
+	var keys []string
+	flush := func() {
+		if len(keys) == 0 {
+			return
+		}
+
+		batch, _ := u.DeleteObjects(ctx, keys)
+		res.Deleted = append(res.Deleted, batch.Deleted...)
+		res.Failed = append(res.Failed, batch.Failed...)
+		keys = keys[:0]
+	}
+
+	err = u.ListObjects(ctx, prefix, func(key string) error {
+		if dryRun {
+			res.Deleted = append(res.Deleted, key)
+			return nil
+		}
+
+		keys = append(keys, key)
+		if len(keys) == maxDeleteBatch {
+			flush()
+		}
+
+		return ctx.Err()
+	})
+	if err != nil {
+		return res, err
+	}
+
+	flush()
+
+	return res, res.Err()
+}
@@ -0,0 +1,128 @@
+package file_storage
+
+import (
+	"sync"
+)
+
+type ProgressEventType int
+
+const (
+	// ProgressTransferred 已上传字节数变化
+	ProgressTransferred ProgressEventType = iota
+	// ProgressPartCompleted 分片上传完成
+	ProgressPartCompleted
+	// ProgressCompleted 上传完成
+	ProgressCompleted
+)
+
+// ProgressEvent 上传进度事件
+type ProgressEvent struct {
+	Type ProgressEventType
+	// Transferred 已上传字节数
+	Transferred int64
+	// Total 总字节数，数据源长度未知时为 -1，直到 ProgressCompleted 事件
+	Total int64
+	// PartNumber 完成的分片序号，仅 ProgressPartCompleted 事件有效
+	PartNumber int
+}
+
+// ProgressListener 上传进度回调，分片并发上传时可能在多个 goroutine 中同时调用，事件顺序不保证，回调需自行保证并发安全
+type ProgressListener func(event ProgressEvent)
+
+// WithProgress 设置上传进度回调
+func WithProgress(listener ProgressListener) UploadOption {
+	return func(o *UploadOptions) {
+		o.Progress = listener
+	}
+}
+
+// progressTracker 汇总各分片的进度并回调，listener 为空时所有方法都不做处理
+type progressTracker struct {
+	mu          sync.Mutex
+	listener    ProgressListener
+	total       int64
+	transferred int64
+}
+
+func newProgressTracker(listener ProgressListener, total int64) *progressTracker {
+	if listener == nil {
+		return nil
+	}
+
+	return &progressTracker{listener: listener, total: total}
+}
+
+// add 增加已上传字节数
+func (p *progressTracker) add(n int64) {
+	if p == nil || n <= 0 {
+		return
+	}
+
+	// 加锁期间只复制进度，解锁后再回调，避免回调阻塞其他分片
+	p.mu.Lock()
+	p.transferred += n
+	if p.total >= 0 {
+		p.transferred = min(p.transferred, p.total)
+	}
+	event := ProgressEvent{Type: ProgressTransferred, Transferred: p.transferred, Total: p.total}
+	p.mu.Unlock()
+
+	p.listener(event)
+}
+
+// partCompleted 分片完成，SDK 不提供字节进度时 size 为该分片的大小，否则传 0
+func (p *progressTracker) partCompleted(partNumber int, size int64) {
+	if p == nil {
+		return
+	}
+
+	p.add(size)
+
+	p.mu.Lock()
+	event := ProgressEvent{Type: ProgressPartCompleted, Transferred: p.transferred, Total: p.total, PartNumber: partNumber}
+	p.mu.Unlock()
+
+	p.listener(event)
+}
+
+func (p *progressTracker) completed() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	if p.total < 0 {
+		p.total = p.transferred
+	}
+	p.transferred = p.total
+	event := ProgressEvent{Type: ProgressCompleted, Transferred: p.total, Total: p.total}
+	p.mu.Unlock()
+
+	p.listener(event)
+}
+
+// Read 供 minio 使用，SDK 每发送一段数据就从 Progress 读取同样长度
+func (p *progressTracker) Read(b []byte) (int, error) {
+	p.add(int64(len(b)))
+	return len(b), nil
+}
+
+// Write 供本地驱动写入文件时统计进度
+func (p *progressTracker) Write(b []byte) (int, error) {
+	p.add(int64(len(b)))
+	return len(b), nil
+}
+
+// consumedTracker 适配各 SDK 的累计进度回调，将单次请求的累计字节数转换为增量
+type consumedTracker struct {
+	tracker  *progressTracker
+	consumed int64
+}
+
+func (c *consumedTracker) update(consumed int64) {
+	// 重试时 SDK 会从 0 重新计数，已计入的部分不再重复累加
+	if consumed > c.consumed {
+		c.tracker.add(consumed - c.consumed)
+		c.consumed = consumed
+	}
+}
@@ -0,0 +1,42 @@
+package file_storage
+
+import (
+	"mime/multipart"
+)
+
+// Source 上传数据源，驱动通过 Open 读取内容，可多次打开
+type Source interface {
+	// Name 原始文件名
+	Name() string
+	// Size 数据长度，单位byte
+	Size() int64
+	Open() (multipart.File, error)
+}
+
+type source struct {
+	name string
+	size int64
+	open func() (multipart.File, error)
+}
+
+// NewSource 使用自定义的打开函数构造上传数据源
+func NewSource(name string, size int64, open func() (multipart.File, error)) Source {
+	return &source{name: name, size: size, open: open}
+}
+
+// FileHeaderSource 将表单文件转换为上传数据源
+func FileHeaderSource(file *multipart.FileHeader) Source {
+	return &source{name: file.Filename, size: file.Size, open: file.Open}
+}
+
+func (s *source) Name() string {
+	return s.name
+}
+
+func (s *source) Size() int64 {
+	return s.size
+}
+
+func (s *source) Open() (multipart.File, error) {
+	return s.open()
+}
@@ -0,0 +1,62 @@
+package file_storage
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+)
+
+type SSEAlgorithm string
+
+const (
+	// SSES3 使用存储服务托管的密钥加密
+	SSES3 SSEAlgorithm = "AES256"
+	// SSEKMS 使用 KMS 托管的密钥加密，KMSKeyID 为空时使用服务端默认密钥
+	SSEKMS SSEAlgorithm = "KMS"
+	// SSEC 使用调用方提供的密钥加密，下载时需提供同一密钥
+	SSEC SSEAlgorithm = "SSE-C"
+)
+
+// ServerSideEncryption 服务端加密配置，各驱动映射为对应 SDK 的请求头
+type ServerSideEncryption struct {
+	Algorithm SSEAlgorithm
+	// KMSKeyID 仅 SSEKMS 使用
+	KMSKeyID string
+	// CustomerKey 仅 SSEC 使用，32 字节
+	CustomerKey []byte
+}
+
+func (s *ServerSideEncryption) validate() error {
+	switch s.Algorithm {
+	case SSES3, SSEKMS:
+		return nil
+	case SSEC:
+		if len(s.CustomerKey) != 32 {
+			return fmt.Errorf("%w: sse-c customer key should be 32 bytes", UnsupportedOptionErr)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: sse algorithm %q", UnsupportedOptionErr, s.Algorithm)
+}
+
+// customerKey SSE-C 密钥的 base64 编码
+func (s *ServerSideEncryption) customerKey() string {
+	return base64.StdEncoding.EncodeToString(s.CustomerKey)
+}
+
+// customerKeyMD5 SSE-C 密钥 MD5 的 base64 编码
+func (s *ServerSideEncryption) customerKeyMD5() string {
+	sum := md5.Sum(s.CustomerKey)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// etagIsMD5 SSE-KMS 和 SSE-C 加密后服务端返回的 ETag 不再是内容的 MD5
+func (s *ServerSideEncryption) etagIsMD5() bool {
+	return s == nil || s.Algorithm == SSES3
+}
+
+// isSSEC 下载 SSE-C 加密的对象时需携带密钥
+func (s *ServerSideEncryption) isSSEC() bool {
+	return s != nil && s.Algorithm == SSEC
+}
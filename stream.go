@@ -0,0 +1,264 @@
+package file_storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qiuyier/file-storage/pkg/util"
+	"io"
+	"mime/multipart"
+)
+
+// streamPartSize 长度未知的数据源按该大小分片上传，单位 MB
+const streamPartSize = 16
+
+var streamConsumedErr = errors.New("stream source has already been read")
+
+// streamSource 长度未知、只能顺序读取一次的数据源
+// 开头的数据会被缓存，开始读取前可多次 Open 并通过 ReadAt 探测文件类型
+type streamSource struct {
+	name     string
+	r        *bufio.Reader
+	consumed bool
+}
+
+// NewStreamSource 将数据流包装为上传数据源，Size 返回 -1，只能上传一次
+// 适用于 multipart 请求中的文件、进程输出等无法预知长度也无法重复读取的数据
+func NewStreamSource(name string, r io.Reader) Source {
+	return &streamSource{name: name, r: bufio.NewReaderSize(r, util.SniffLen)}
+}
+
+func (s *streamSource) Name() string {
+	return s.name
+}
+
+func (s *streamSource) Size() int64 {
+	return -1
+}
+
+func (s *streamSource) Open() (multipart.File, error) {
+	if s.consumed {
+		return nil, streamConsumedErr
+	}
+
+	return &streamFile{s: s}, nil
+}
+
+// streamFile 数据流的读取视图，ReadAt 只能读取尚未消费的开头部分
+type streamFile struct {
+	s *streamSource
+}
+
+func (f *streamFile) Read(p []byte) (int, error) {
+	f.s.consumed = true
+	return f.s.r.Read(p)
+}
+
+func (f *streamFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.s.consumed {
+		return 0, streamConsumedErr
+	}
+
+	end := off + int64(len(p))
+	if end > int64(f.s.r.Size()) {
+		return 0, fmt.Errorf("read stream at %d: beyond buffered head", off)
+	}
+
+	head, err := f.s.r.Peek(int(end))
+	if int64(len(head)) <= off {
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+
+	n := copy(p, head[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Seek 只支持在开始读取前回到开头，驱动读取前通常会先执行一次
+func (f *streamFile) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && (whence == io.SeekStart || whence == io.SeekCurrent) && !f.s.consumed {
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("%w: seek stream source", UnsupportedOptionErr)
+}
+
+func (f *streamFile) Close() error {
+	return nil
+}
+
+// streamCounter 读取数据流时统计长度，并按校验规则限制大小
+type streamCounter struct {
+	r     io.Reader
+	name  string
+	rules *ValidationRules
+	n     int64
+}
+
+func (c *streamCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	if c.rules == nil {
+		return n, err
+	}
+
+	if c.rules.MaxSize > 0 && c.n > c.rules.MaxSize {
+		return n, &ValidationError{
+			FileName: c.name,
+			Err:      FileTooLargeErr,
+			Detail:   fmt.Sprintf("size over %s, max %s", util.FileSize(c.n), util.FileSize(c.rules.MaxSize)),
+		}
+	}
+
+	if err == io.EOF && c.rules.MinSize > 0 && c.n < c.rules.MinSize {
+		return n, &ValidationError{
+			FileName: c.name,
+			Err:      FileTooSmallErr,
+			Detail:   fmt.Sprintf("size %s, min %s", util.FileSize(c.n), util.FileSize(c.rules.MinSize)),
+		}
+	}
+
+	return n, err
+}
+
+// size 数据源为数据流时返回实际读取的长度
+func (c *streamCounter) size(file Source) int64 {
+	if c == nil {
+		return file.Size()
+	}
+
+	return c.n
+}
+
+// countStream 长度未知的数据源无法预先校验大小，改为在驱动读取时统计并校验
+func countStream(file Source, rules *ValidationRules) (Source, *streamCounter, error) {
+	fd, err := file.Open()
+	if err != nil {
+		return nil, nil, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
+
+	counter := &streamCounter{r: fd, name: file.Name(), rules: rules}
+
+	return NewStreamSource(file.Name(), counter), counter, nil
+}
+
+// maxStreamParts 分片上传最多的分片数量
+const maxStreamParts = 10000
+
+// chunkReader 依次返回上传分片，长度已知时预先切分，长度未知时每次从数据流读取一个分片到内存
+type chunkReader struct {
+	chunks    []util.FileChunk
+	r         io.Reader
+	chunkSize int64
+	number    int
+	offset    int64
+	done      bool
+}
+
+func newChunkReader(fd multipart.File, size, chunkSize int64) (*chunkReader, error) {
+	if size >= 0 {
+		chunks, err := util.SplitFileByPartSize(fd, size, chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		return &chunkReader{chunks: chunks}, nil
+	}
+
+	if chunkSize <= 0 {
+		return nil, errors.New("chunkSize invalid")
+	}
+
+	return &chunkReader{r: fd, chunkSize: chunkSize}, nil
+}
+
+// next 没有更多分片时返回 io.EOF，空数据流返回一个空分片
+func (c *chunkReader) next() (util.FileChunk, error) {
+	if c.r == nil {
+		if len(c.chunks) == 0 {
+			return util.FileChunk{}, io.EOF
+		}
+
+		chunk := c.chunks[0]
+		c.chunks = c.chunks[1:]
+		return chunk, nil
+	}
+
+	if c.done {
+		return util.FileChunk{}, io.EOF
+	}
+
+	buf := make([]byte, c.chunkSize)
+	n, err := io.ReadFull(c.r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.done = true
+		if n == 0 && c.number > 0 {
+			return util.FileChunk{}, io.EOF
+		}
+	} else if err != nil {
+		return util.FileChunk{}, err
+	}
+
+	if c.number >= maxStreamParts {
+		return util.FileChunk{}, errors.New("too many parts, please increase part size")
+	}
+
+	c.number++
+	chunk := util.FileChunk{
+		Number: c.number,
+		Offset: c.offset,
+		Size:   int64(n),
+		Buf:    bytes.NewReader(buf[:n]),
+	}
+	c.offset += int64(n)
+
+	return chunk, nil
+}
+
+// UploadMultipartReader 顺序读取 multipart 请求体中的文件并直接交给驱动上传，文件内容不会先缓存到内存或磁盘
+// 非文件字段会被跳过，单个文件上传失败不影响后续文件，读取请求体失败时停止并返回错误
+func (u *Uploader) UploadMultipartReader(ctx context.Context, reader *multipart.Reader, randomName bool, opts ...UploadOption) ([]BatchResult, error) {
+	return u.uploadParts(ctx, reader, randomName, nil, opts)
+}
+
+// uploadParts accept 返回 false 时跳过该文件，返回错误时停止读取
+func (u *Uploader) uploadParts(ctx context.Context, reader *multipart.Reader, randomName bool, accept func(part *multipart.Part) (bool, error), opts []UploadOption) ([]BatchResult, error) {
+	var results []BatchResult
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			u.logger.Errorf("read multipart err: %v", err)
+			return results, err
+		}
+
+		if part.FileName() == "" {
+			continue
+		}
+
+		if accept != nil {
+			ok, err := accept(part)
+			if err != nil {
+				return results, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		result := BatchResult{FileName: part.FileName()}
+		result.Result, result.Err = u.UploadSource(ctx, NewStreamSource(part.FileName(), part), randomName, opts...)
+		results = append(results, result)
+	}
+}
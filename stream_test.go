@@ -0,0 +1,51 @@
+package file_storage
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestChunkReader(t *testing.T) {
+	for _, tc := range []struct {
+		size  int
+		sizes []int64
+	}{
+		{0, []int64{0}},
+		{8, []int64{4, 4}},
+		{10, []int64{4, 4, 2}},
+	} {
+		content := bytes.Repeat([]byte("a"), tc.size)
+		source := NewStreamSource("a.txt", bytes.NewReader(content))
+		fd, _ := source.Open()
+
+		chunks, err := newChunkReader(fd, source.Size(), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sizes []int64
+		for {
+			chunk, err := chunks.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chunk.Number != len(sizes)+1 || chunk.Offset != int64(4*len(sizes)) {
+				t.Fatalf("size %d: unexpected chunk %+v", tc.size, chunk)
+			}
+			sizes = append(sizes, chunk.Size)
+		}
+
+		if len(sizes) != len(tc.sizes) {
+			t.Fatalf("size %d: chunk sizes %v, expect %v", tc.size, sizes, tc.sizes)
+		}
+		for i := range sizes {
+			if sizes[i] != tc.sizes[i] {
+				t.Fatalf("size %d: chunk sizes %v, expect %v", tc.size, sizes, tc.sizes)
+			}
+		}
+	}
+}
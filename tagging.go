@@ -0,0 +1,84 @@
+package file_storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"unicode/utf8"
+)
+
+const (
+	// maxObjectTags 各厂商单个对象最多 10 个标签
+	maxObjectTags = 10
+	// maxTagKeyLength、maxTagValueLength 标签键值的最大字符数
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// WithTags 设置对象的初始标签，多次调用会合并，七牛驱动不支持
+func WithTags(tags map[string]string) UploadOption {
+	return func(o *UploadOptions) {
+		if o.Tags == nil {
+			o.Tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			o.Tags[k] = v
+		}
+	}
+}
+
+// validateTags 按各厂商的共同限制校验标签
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxObjectTags {
+		return fmt.Errorf("%w: at most %d tags per object", InvalidTagErr, maxObjectTags)
+	}
+
+	for k, v := range tags {
+		if k == "" || utf8.RuneCountInString(k) > maxTagKeyLength {
+			return fmt.Errorf("%w: key %q must be 1-%d characters", InvalidTagErr, k, maxTagKeyLength)
+		}
+		if utf8.RuneCountInString(v) > maxTagValueLength {
+			return fmt.Errorf("%w: value of %q exceeds %d characters", InvalidTagErr, k, maxTagValueLength)
+		}
+	}
+
+	return nil
+}
+
+// encodeTags 上传时通过 x-*-tagging 请求头设置标签，格式与 URL 查询参数相同
+func encodeTags(tags map[string]string) string {
+	values := make(url.Values, len(tags))
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+
+	return values.Encode()
+}
+
+func (u *Uploader) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	tags, err := u.uploader.GetObjectTags(ctx, path)
+	if err != nil {
+		u.logger.Errorf("get object tags %s err: %v", path, err)
+	}
+
+	return tags, err
+}
+
+// SetObjectTags 替换对象的全部标签
+func (u *Uploader) SetObjectTags(ctx context.Context, path string, tags map[string]string) error {
+	err := u.uploader.SetObjectTags(ctx, path, tags)
+	if err != nil {
+		u.logger.Errorf("set object tags %s err: %v", path, err)
+	}
+
+	return err
+}
+
+func (u *Uploader) DeleteObjectTags(ctx context.Context, path string) error {
+	err := u.uploader.DeleteObjectTags(ctx, path)
+	if err != nil {
+		u.logger.Errorf("delete object tags %s err: %v", path, err)
+	}
+
+	return err
+}
@@ -0,0 +1,133 @@
+package file_storage
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"io"
+)
+
+// throttleChunk 单次读取的最大字节数，避免一次申请过多令牌导致速率忽高忽低
+const throttleChunk = 32 * 1024
+
+// RateLimiter 令牌桶限速器，单位 byte/s，可在多个上传、下载之间共享以限制总带宽
+type RateLimiter struct {
+	limiter *rate.Limiter
+}
+
+// NewRateLimiter bytesPerSecond 为每秒允许传输的字节数，小于等于 0 时不限速
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	limit := rate.Limit(bytesPerSecond)
+	if bytesPerSecond <= 0 {
+		limit = rate.Inf
+	}
+
+	return &RateLimiter{
+		limiter: rate.NewLimiter(limit, int(max(bytesPerSecond, throttleChunk))),
+	}
+}
+
+// WithRateLimit 限制本次上传的速率，单位 byte/s，与 Uploader 的全局限速同时生效
+func WithRateLimit(bytesPerSecond int64) UploadOption {
+	return WithRateLimiter(NewRateLimiter(bytesPerSecond))
+}
+
+// WithRateLimiter 使用共享的限速器限制本次上传的速率，多次设置时同时生效
+func WithRateLimiter(limiter *RateLimiter) UploadOption {
+	return func(o *UploadOptions) {
+		o.RateLimiters = append(o.RateLimiters, limiter)
+	}
+}
+
+// readSeekerAt 驱动传给 SDK 的上传内容，文件和分片均满足
+type readSeekerAt interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// throttledReader 读取后按字节数从所有限速器获取令牌
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*RateLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+
+	n, err := t.r.Read(p)
+	if waitErr := t.wait(n); waitErr != nil {
+		return n, waitErr
+	}
+
+	return n, err
+}
+
+func (t *throttledReader) wait(n int) error {
+	for _, l := range t.limiters {
+		if err := l.limiter.WaitN(t.ctx, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// throttledFile 保留 ReaderAt 和 Seeker，SDK 可以继续获取长度、重试及并发读取
+type throttledFile struct {
+	throttledReader
+	f readSeekerAt
+}
+
+func (t *throttledFile) ReadAt(p []byte, off int64) (int, error) {
+	var read int
+	for read < len(p) {
+		end := min(len(p), read+throttleChunk)
+
+		n, err := t.f.ReadAt(p[read:end], off+int64(read))
+		read += n
+		if waitErr := t.wait(n); waitErr != nil {
+			return read, waitErr
+		}
+		if err != nil {
+			return read, err
+		}
+	}
+
+	return read, nil
+}
+
+func (t *throttledFile) Seek(offset int64, whence int) (int64, error) {
+	return t.f.Seek(offset, whence)
+}
+
+// throttle 包装传给 SDK 的上传内容，未设置限速时原样返回
+func throttle(ctx context.Context, f readSeekerAt, limiters []*RateLimiter) readSeekerAt {
+	if len(limiters) == 0 {
+		return f
+	}
+
+	return &throttledFile{
+		throttledReader: throttledReader{ctx: ctx, r: f, limiters: limiters},
+		f:               f,
+	}
+}
+
+type throttledReadCloser struct {
+	throttledReader
+	io.Closer
+}
+
+// throttleReadCloser 包装下载内容
+func throttleReadCloser(ctx context.Context, rc io.ReadCloser, limiters ...*RateLimiter) io.ReadCloser {
+	if len(limiters) == 0 {
+		return rc
+	}
+
+	return &throttledReadCloser{
+		throttledReader: throttledReader{ctx: ctx, r: rc, limiters: limiters},
+		Closer:          rc,
+	}
+}
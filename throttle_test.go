@@ -0,0 +1,36 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	content := bytes.Repeat([]byte("a"), 150*1024)
+
+	// 令牌桶初始有 100K 的余量，剩余 50K 需要约 0.5s
+	start := time.Now()
+	_, err := uploader.Upload(context.Background(), newSource(t, "a.txt", content), false, WithRateLimit(100*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("upload not throttled, took %s", elapsed)
+	}
+}
+
+func TestRateLimitCanceled(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	content := bytes.Repeat([]byte("a"), 1024*1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := uploader.Upload(ctx, newSource(t, "a.txt", content), false, WithRateLimit(64*1024)); err == nil {
+		t.Fatal("expected error after context canceled")
+	}
+}
@@ -0,0 +1,189 @@
+package file_storage
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTrashPrefix 回收站默认前缀
+	defaultTrashPrefix = ".trash"
+	// defaultTrashRetention 回收站默认保留时长
+	defaultTrashRetention = 30 * 24 * time.Hour
+)
+
+// TrashConfig 回收站配置，开启后 Uploader.DeleteObjects 将对象移动到 <Prefix>/<日期>/<原路径>，不再直接删除
+type TrashConfig struct {
+	// Prefix 回收站前缀，默认为 .trash
+	// 本地驱动的 Prefix 需位于 LocalPath 之内，如 LocalPath 为 uploads 时设置为 uploads/.trash
+	// 开启 FileServer 时最后一级目录需以 . 开头，FileServer 不提供以 . 开头的路径，否则回收站中的对象仍可被访问
+	Prefix string
+	// Retention 保留时长，PurgeTrash 永久删除超过该时长的对象，小于等于 0 时为 30 天
+	Retention time.Duration
+}
+
+// SetTrash 开启回收站
+func (u *Uploader) SetTrash(config TrashConfig) *Uploader {
+	if config.Prefix == "" {
+		config.Prefix = defaultTrashPrefix
+	}
+	config.Prefix = path.Clean(config.Prefix)
+
+	if config.Retention <= 0 {
+		config.Retention = defaultTrashRetention
+	}
+
+	u.trash = &config
+	return u
+}
+
+// Restore 将回收站中的对象恢复到原路径，原路径已存在时返回 ObjectExistsErr
+func (u *Uploader) Restore(ctx context.Context, trashPath string) (string, error) {
+	if u.trash == nil {
+		return "", TrashDisabledErr
+	}
+
+	original, err := u.trash.original(trashPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err = u.uploader.MoveObject(ctx, trashPath, original, WithNoOverwrite()); err != nil {
+		u.logger.Errorf("restore %s err: %v", trashPath, err)
+		return "", err
+	}
+
+	return original, nil
+}
+
+// PurgeTrash 永久删除回收站中超过保留时长的对象，可由定时任务调用
+func (u *Uploader) PurgeTrash(ctx context.Context) (res DeleteResult, err error) {
+	if u.trash == nil {
+		return res, TrashDisabledErr
+	}
+
+	root := u.trash.Prefix + "/"
+	cutoff := time.Now().Add(-u.trash.Retention)
+
+	// 按日期目录删除，同一天删除的对象全部过期后才清理
+	expired := make(map[string]struct{})
+	err = u.uploader.ListObjects(ctx, root, func(key string) error {
+		date, _, _ := strings.Cut(strings.TrimPrefix(key, root), "/")
+		day, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+		if err == nil && !day.AddDate(0, 0, 1).After(cutoff) {
+			expired[date] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		u.logger.Errorf("purge trash err: %v", err)
+		return res, err
+	}
+
+	dates := make([]string, 0, len(expired))
+	for date := range expired {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		batch, err := u.uploader.DeletePrefix(ctx, root+date+"/", false)
+		res.Deleted = append(res.Deleted, batch.Deleted...)
+		res.Failed = append(res.Failed, batch.Failed...)
+		if err != nil && len(batch.Failed) == 0 {
+			res.fail(root+date+"/", "", err)
+		}
+	}
+
+	if err = res.Err(); err != nil {
+		u.logger.Errorf("purge trash err: %v", err)
+	}
+
+	return res, err
+}
+
+// moveToTrash 将对象移动到回收站，已在回收站中的对象直接删除
+func (u *Uploader) moveToTrash(ctx context.Context, paths []string) (res DeleteResult, err error) {
+	date := time.Now().Format(time.DateOnly)
+
+	var permanent []string
+	for _, v := range paths {
+		if u.trash.contains(v) {
+			permanent = append(permanent, v)
+			continue
+		}
+
+		if err := u.uploader.MoveObject(ctx, v, u.trash.key(date, v)); err != nil && !errors.Is(err, ObjectNotFoundErr) {
+			res.fail(v, "", err)
+			continue
+		}
+		res.Deleted = append(res.Deleted, v)
+	}
+
+	if len(permanent) > 0 {
+		batch, _ := u.uploader.DeleteObjects(ctx, permanent)
+		res.Deleted = append(res.Deleted, batch.Deleted...)
+		res.Failed = append(res.Failed, batch.Failed...)
+	}
+
+	return res, res.Err()
+}
+
+// trashPrefix 先列出前缀下的对象再逐个移动到回收站，dryRun 时只返回将被删除的对象
+func (u *Uploader) trashPrefix(ctx context.Context, prefix string, dryRun bool) (res DeleteResult, err error) {
+	if err = checkPrefix(prefix); err != nil {
+		return
+	}
+
+	var keys []string
+	err = u.uploader.ListObjects(ctx, prefix, func(key string) error {
+		keys = append(keys, key)
+		return ctx.Err()
+	})
+	if err != nil {
+		return
+	}
+
+	if dryRun {
+		res.Deleted = keys
+		return res, nil
+	}
+
+	return u.moveToTrash(ctx, keys)
+}
+
+func (c *TrashConfig) contains(p string) bool {
+	return strings.HasPrefix(p, c.Prefix+"/")
+}
+
+// key 回收站中的路径
+func (c *TrashConfig) key(date, p string) string {
+	return c.Prefix + "/" + date + "/" + strings.TrimPrefix(p, "/")
+}
+
+// original 由回收站中的路径还原原路径
+func (c *TrashConfig) original(trashPath string) (string, error) {
+	rest, ok := strings.CutPrefix(trashPath, c.Prefix+"/")
+	if !ok {
+		return "", NotInTrashErr
+	}
+
+	date, p, ok := strings.Cut(rest, "/")
+	if !ok || p == "" {
+		return "", NotInTrashErr
+	}
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return "", NotInTrashErr
+	}
+
+	// 绝对路径放入回收站时去掉了开头的 /
+	if strings.HasPrefix(c.Prefix, "/") {
+		p = "/" + p
+	}
+
+	return p, nil
+}
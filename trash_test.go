@@ -0,0 +1,123 @@
+package file_storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	root := t.TempDir()
+	driver, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	uploader := NewFileUploader().RegisterUploader(driver).SetTrash(TrashConfig{Prefix: filepath.Join(root, ".trash"), Retention: 24 * time.Hour})
+	ctx := context.Background()
+
+	res, err := uploader.UploadSource(ctx, newSource(t, "a.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = uploader.DeleteObjects(ctx, []string{res.Path}); err != nil {
+		t.Fatal(err)
+	}
+	trashPath := filepath.Join(root, ".trash", time.Now().Format(time.DateOnly), res.Path)
+	trashMeta, _ := driver.metaPath(trashPath)
+	if exists(res.Path) || !exists(trashPath) || !exists(trashMeta) {
+		t.Fatalf("object was not moved to trash")
+	}
+
+	original, err := uploader.Restore(ctx, trashPath)
+	if err != nil || original != res.Path || !exists(res.Path) {
+		t.Fatalf("restore failed: %s, %v", original, err)
+	}
+	// 原路径已存在时不覆盖
+	_, _ = uploader.DeleteObjects(ctx, []string{res.Path})
+	_ = os.MkdirAll(filepath.Dir(res.Path), 0777)
+	_ = os.WriteFile(res.Path, []byte("new"), 0666)
+	if _, err = uploader.Restore(ctx, trashPath); !errors.Is(err, ObjectExistsErr) {
+		t.Fatalf("expected ObjectExistsErr, got %v", err)
+	}
+	if data, _ := os.ReadFile(res.Path); string(data) != "new" || !exists(trashPath) {
+		t.Fatal("restore overwrote the existing object")
+	}
+	_ = os.Remove(res.Path)
+	if _, err = uploader.Restore(ctx, trashPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = uploader.Restore(ctx, res.Path); !errors.Is(err, NotInTrashErr) {
+		t.Fatalf("expected NotInTrashErr, got %v", err)
+	}
+
+	// 今天删除的对象未过期，两天前的对象过期
+	_, _ = uploader.DeleteObjects(ctx, []string{res.Path})
+	expired := filepath.Join(root, ".trash", time.Now().AddDate(0, 0, -2).Format(time.DateOnly), "b.txt")
+	_ = os.MkdirAll(filepath.Dir(expired), 0777)
+	_ = os.WriteFile(expired, []byte("old"), 0666)
+
+	purged, err := uploader.PurgeTrash(ctx)
+	if err != nil || len(purged.Deleted) != 1 || purged.Deleted[0] != expired {
+		t.Fatalf("unexpected purge result %v, %v", purged.Deleted, err)
+	}
+	if exists(filepath.Dir(expired)) || !exists(trashPath) {
+		t.Fatal("purge removed the wrong objects")
+	}
+}
+
+func TestTrashDirectory(t *testing.T) {
+	root := t.TempDir()
+	driver, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	uploader := NewFileUploader().RegisterUploader(driver).SetTrash(TrashConfig{Prefix: filepath.Join(root, ".trash")})
+	ctx := context.Background()
+
+	res, err := uploader.UploadSource(ctx, newSource(t, "a.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirPath := filepath.Dir(res.Path)
+
+	if _, err = uploader.DeleteObjects(ctx, []string{dirPath}); err != nil {
+		t.Fatal(err)
+	}
+	trashPath := filepath.Join(root, ".trash", time.Now().Format(time.DateOnly), dirPath)
+	if exists(dirPath) || !exists(filepath.Join(trashPath, "a.txt")) {
+		t.Fatal("directory was not moved to trash")
+	}
+
+	original, err := uploader.Restore(ctx, trashPath)
+	if err != nil || original != dirPath {
+		t.Fatalf("restore failed: %s, %v", original, err)
+	}
+	if data, _ := os.ReadFile(res.Path); string(data) != "hello" {
+		t.Fatalf("unexpected restored content %q", data)
+	}
+	if meta, _ := driver.metaPath(res.Path); !exists(meta) {
+		t.Fatal("meta was not restored")
+	}
+}
+
+func TestTrashDeletePrefix(t *testing.T) {
+	root := t.TempDir()
+	driver, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	uploader := NewFileUploader().RegisterUploader(driver).SetTrash(TrashConfig{Prefix: filepath.Join(root, ".trash")})
+	ctx := context.Background()
+
+	p := filepath.Join(root, "dir", "a.txt")
+	_ = os.MkdirAll(filepath.Dir(p), 0777)
+	_ = os.WriteFile(p, []byte("hello"), 0666)
+
+	res, err := uploader.DeletePrefix(ctx, filepath.Join(root, "dir")+"/", true)
+	if err != nil || len(res.Deleted) != 1 || !exists(p) {
+		t.Fatalf("unexpected dry run result %v, %v", res.Deleted, err)
+	}
+
+	if res, err = uploader.DeletePrefix(ctx, filepath.Join(root, "dir")+"/", false); err != nil || len(res.Deleted) != 1 {
+		t.Fatalf("unexpected result %v, %v", res.Deleted, err)
+	}
+	if exists(p) || !exists(filepath.Join(root, ".trash", time.Now().Format(time.DateOnly), p)) {
+		t.Fatal("object was not moved to trash")
+	}
+}
@@ -5,30 +5,78 @@ import (
 	"github.com/qiuyier/file-storage/pkg/log"
 	"github.com/qiuyier/file-storage/pkg/util"
 	"go.uber.org/zap/zapcore"
+	"io"
 	"mime/multipart"
 )
 
 type Uploader struct {
-	uploader IUpload
-	logger   *log.Logger
+	uploader    IUpload
+	logger      *log.Logger
+	validation  *ValidationRules
+	rateLimiter *RateLimiter
+	trash       *TrashConfig
 }
 
 type UploadResult struct {
-	Driver   string
-	FileName string
+	Driver      string   `json:"driver"`
+	FileName    string   `json:"file_name"`
+	Path        string   `json:"path,omitempty"`
+	Size        string   `json:"size"`
+	FileUrl     string   `json:"file_url"`
+	Ext         string   `json:"ext"`
+	ContentType string   `json:"content_type"`
+	Checksum    Checksum `json:"checksum"`
+	// VersionID 存储桶开启多版本时为本次上传产生的版本号
+	VersionID string `json:"version_id,omitempty"`
+}
+
+// ObjectResult 驱动上传结果
+type ObjectResult struct {
 	Path     string
-	Size     string
 	FileUrl  string
-	Ext      string
+	Checksum Checksum
+	// VersionID 存储桶开启多版本时为本次上传产生的版本号
+	VersionID string
 }
 
 type IUpload interface {
-	Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error)
+	Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error)
 	// MultipartUpload
 	//chunkSize 单位byte
-	MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error)
+	MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error)
 	GetUploaderType() string
-	DeleteObjects(ctx context.Context, path []string) error
+	// Exists 判断对象是否存在
+	Exists(ctx context.Context, path string) (bool, error)
+	// GetObject 下载对象，调用方负责关闭，对象不存在时返回 ObjectNotFoundErr，WithRange 可只读取部分内容，加密、压缩的对象仍从头读取
+	GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error)
+	// StatObject 获取对象属性，对象不存在时返回 ObjectNotFoundErr
+	StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error)
+	// DeleteObjects 批量删除，自动按厂商上限分批，存在删除失败的对象时 error 不为空
+	DeleteObjects(ctx context.Context, path []string) (DeleteResult, error)
+	// DeletePrefix 递归删除前缀下的所有对象，dryRun 时不删除，只在 Deleted 中返回将被删除的对象
+	// 前缀为空或只包含 / 时返回 EmptyPrefixErr
+	DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeleteResult, error)
+	// ListObjects 按前缀递归遍历所有对象，fn 返回错误时停止遍历并返回该错误
+	ListObjects(ctx context.Context, prefix string, fn func(key string) error) error
+	// MoveObject 移动对象，保留访问权限，目标已存在时覆盖，WithNoOverwrite 时返回 ObjectExistsErr，源对象不存在时返回 ObjectNotFoundErr
+	// 超过单次复制上限的对象自动改为分片复制
+	MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error
+	// ListVersions 列出对象的所有版本，按时间由新到旧排列，不支持多版本的驱动返回 UnsupportedOptionErr
+	ListVersions(ctx context.Context, path string) ([]ObjectVersion, error)
+	// DeleteVersion 永久删除对象的指定版本，删除当前版本时最新的历史版本成为当前版本
+	DeleteVersion(ctx context.Context, path, versionID string) error
+	// RestoreVersion 将指定版本复制为最新版本，返回新版本号
+	RestoreVersion(ctx context.Context, path, versionID string) (string, error)
+	// GetObjectTags 获取对象标签，没有标签时返回空 map
+	GetObjectTags(ctx context.Context, path string) (map[string]string, error)
+	// SetObjectTags 替换对象的全部标签
+	SetObjectTags(ctx context.Context, path string, tags map[string]string) error
+	// DeleteObjectTags 删除对象的全部标签
+	DeleteObjectTags(ctx context.Context, path string) error
+	// SetACL 修改对象的访问权限
+	SetACL(ctx context.Context, path string, acl ACL) error
+	// Capabilities 驱动支持的功能
+	Capabilities() Capabilities
 }
 
 func NewFileUploader() *Uploader {
@@ -40,49 +88,183 @@ func NewFileUploader() *Uploader {
 	}
 }
 
-func (u *Uploader) Upload(ctx context.Context, file *multipart.FileHeader, randomName bool) (res UploadResult, err error) {
-	path, fileUrl, err := u.uploader.Upload(ctx, file, randomName)
+func (u *Uploader) Upload(ctx context.Context, file *multipart.FileHeader, randomName bool, opts ...UploadOption) (res UploadResult, err error) {
+	return u.UploadSource(ctx, FileHeaderSource(file), randomName, opts...)
+}
+
+// UploadSource 上传任意数据源
+func (u *Uploader) UploadSource(ctx context.Context, file Source, randomName bool, opts ...UploadOption) (res UploadResult, err error) {
+	if err = u.validate(file); err != nil {
+		u.logger.Errorf("upload validate err: %v", err)
+		return
+	}
+
+	contentType, opts := withContentType(file, opts)
+	opts = u.withRateLimiter(opts)
+
+	file, counter, err := u.countStream(file)
+	if err != nil {
+		u.logger.Errorf("upload err: %v", err)
+		return
+	}
+
+	object, err := u.uploader.Upload(ctx, file, randomName, opts...)
 	if err != nil {
 		u.logger.Errorf("upload err: %v", err)
 	}
 
 	res = UploadResult{
-		Driver:   u.uploader.GetUploaderType(),
-		FileName: file.Filename,
-		Path:     path,
-		Size:     util.FileSize(file.Size),
-		FileUrl:  fileUrl,
-		Ext:      util.Ext(file.Filename),
+		Driver:      u.uploader.GetUploaderType(),
+		FileName:    file.Name(),
+		Path:        object.Path,
+		Size:        util.FileSize(counter.size(file)),
+		FileUrl:     object.FileUrl,
+		Ext:         util.Ext(file.Name()),
+		ContentType: contentType,
+		Checksum:    object.Checksum,
+		VersionID:   object.VersionID,
 	}
 
 	return
 }
 
-func (u *Uploader) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomName bool, chunkSize int) (res UploadResult, err error) {
-	path, fileUrl, err := u.uploader.MultipartUpload(ctx, file, randomName, chunkSize)
+func (u *Uploader) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomName bool, chunkSize int, opts ...UploadOption) (res UploadResult, err error) {
+	return u.MultipartUploadSource(ctx, FileHeaderSource(file), randomName, chunkSize, opts...)
+}
+
+// MultipartUploadSource 分片上传任意数据源
+func (u *Uploader) MultipartUploadSource(ctx context.Context, file Source, randomName bool, chunkSize int, opts ...UploadOption) (res UploadResult, err error) {
+	if err = u.validate(file); err != nil {
+		u.logger.Errorf("multipart upload validate err: %v", err)
+		return
+	}
+
+	contentType, opts := withContentType(file, opts)
+	opts = u.withRateLimiter(opts)
+
+	file, counter, err := u.countStream(file)
+	if err != nil {
+		u.logger.Errorf("multipart upload err: %v", err)
+		return
+	}
+
+	object, err := u.uploader.MultipartUpload(ctx, file, randomName, chunkSize, opts...)
 	if err != nil {
 		u.logger.Errorf("multipart upload err: %v", err)
 	}
 
 	res = UploadResult{
-		Driver:   u.uploader.GetUploaderType(),
-		FileName: file.Filename,
-		Path:     path,
-		Size:     util.FileSize(file.Size),
-		FileUrl:  fileUrl,
-		Ext:      util.Ext(file.Filename),
+		Driver:      u.uploader.GetUploaderType(),
+		FileName:    file.Name(),
+		Path:        object.Path,
+		Size:        util.FileSize(counter.size(file)),
+		FileUrl:     object.FileUrl,
+		Ext:         util.Ext(file.Name()),
+		ContentType: contentType,
+		Checksum:    object.Checksum,
+		VersionID:   object.VersionID,
 	}
 
 	return
 }
 
-func (u *Uploader) DeleteObjects(ctx context.Context, path []string) error {
-	err := u.uploader.DeleteObjects(ctx, path)
+func (u *Uploader) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	rc, err := u.uploader.GetObject(ctx, path, opts...)
+	if err != nil {
+		u.logger.Errorf("get object err: %v", err)
+		return nil, err
+	}
+
+	if u.rateLimiter != nil {
+		rc = throttleReadCloser(ctx, rc, u.rateLimiter)
+	}
+
+	return rc, nil
+}
+
+func (u *Uploader) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	info, err := u.uploader.StatObject(ctx, path, opts...)
+	if err != nil {
+		u.logger.Errorf("stat object err: %v", err)
+	}
+
+	return info, err
+}
+
+// DeleteObjects 开启回收站时将对象移动到回收站，否则永久删除
+func (u *Uploader) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
+	if u.trash != nil {
+		res, err = u.moveToTrash(ctx, path)
+	} else {
+		res, err = u.uploader.DeleteObjects(ctx, path)
+	}
 	if err != nil {
 		u.logger.Errorf("delete err: %v", err)
 	}
 
-	return err
+	return res, err
+}
+
+// DeletePrefix 开启回收站时将前缀下的对象移动到回收站，否则永久删除
+func (u *Uploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (res DeleteResult, err error) {
+	if u.trash != nil {
+		res, err = u.trashPrefix(ctx, prefix, dryRun)
+	} else {
+		res, err = u.uploader.DeletePrefix(ctx, prefix, dryRun)
+	}
+	if err != nil {
+		u.logger.Errorf("delete prefix %s err: %v", prefix, err)
+	}
+
+	return res, err
+}
+
+// withContentType 未指定 ContentType 时在上传前探测，保证驱动与 UploadResult 使用同一类型
+func withContentType(file Source, opts []UploadOption) (string, []UploadOption) {
+	if o := newUploadOptions(opts...); o.ContentType != "" {
+		return o.ContentType, opts
+	}
+
+	fd, err := file.Open()
+	if err != nil {
+		return "", opts
+	}
+	defer fd.Close()
+
+	contentType := util.SniffContentType(fd, file.Name())
+
+	return contentType, append(opts[:len(opts):len(opts)], WithContentType(contentType))
+}
+
+// withRateLimiter 追加全局限速
+func (u *Uploader) withRateLimiter(opts []UploadOption) []UploadOption {
+	if u.rateLimiter == nil {
+		return opts
+	}
+
+	return append(opts[:len(opts):len(opts)], WithRateLimiter(u.rateLimiter))
+}
+
+// countStream 长度未知的数据源在上传时统计长度并校验大小
+func (u *Uploader) countStream(file Source) (Source, *streamCounter, error) {
+	if file.Size() >= 0 {
+		return file, nil, nil
+	}
+
+	return countStream(file, u.validation)
+}
+
+func (u *Uploader) validate(file Source) error {
+	if u.validation == nil {
+		return nil
+	}
+
+	return u.validation.Validate(file)
+}
+
+// Capabilities 当前驱动支持的功能
+func (u *Uploader) Capabilities() Capabilities {
+	return u.uploader.Capabilities()
 }
 
 func (u *Uploader) RegisterUploader(uploader IUpload) *Uploader {
@@ -90,6 +272,21 @@ func (u *Uploader) RegisterUploader(uploader IUpload) *Uploader {
 	return u
 }
 
+// SetValidation 设置上传校验规则，未通过校验的文件不会交给驱动
+func (u *Uploader) SetValidation(rules ValidationRules) *Uploader {
+	u.validation = &rules
+	return u
+}
+
+// SetRateLimit 限制该 Uploader 所有上传、下载的总速率，单位 byte/s，小于等于 0 时不限速
+func (u *Uploader) SetRateLimit(bytesPerSecond int64) *Uploader {
+	u.rateLimiter = nil
+	if bytesPerSecond > 0 {
+		u.rateLimiter = NewRateLimiter(bytesPerSecond)
+	}
+	return u
+}
+
 func (u *Uploader) SetLogName(appName string) *Uploader {
 	u.logger.SetLogName(appName)
 	return u
@@ -2,13 +2,16 @@ package file_storage
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/qiuyier/file-storage/pkg/util"
 	"github.com/tencentyun/cos-go-sdk-v5"
-	"mime/multipart"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 type UploaderCosConfig struct {
@@ -19,12 +22,25 @@ type UploaderCosConfig struct {
 	Path            string
 	Domain          string
 	Region          string
+	Overwrite       OverwritePolicy
+	// SSE 服务端加密
+	SSE *ServerSideEncryption
+	// ACL 默认访问权限，为空时继承存储桶设置
+	ACL ACL
+	// URLExpires 私有对象签名 URL 的有效期，默认 1 小时
+	URLExpires time.Duration
+	// DisableSSL Domain 及 EndPoint 未带协议头时使用 http，默认 https
+	DisableSSL bool
 }
 
 type UploaderCos struct {
-	client *cos.Client
-	path   string
-	domain string
+	client     *cos.Client
+	path       string
+	url        urlBuilder
+	overwrite  OverwritePolicy
+	sse        *ServerSideEncryption
+	acl        ACL
+	urlExpires time.Duration
 }
 
 func NewUploaderCos(config UploaderCosConfig) (uploader *UploaderCos, err error) {
@@ -42,29 +58,93 @@ func NewUploaderCos(config UploaderCosConfig) (uploader *UploaderCos, err error)
 	})
 
 	uploader = &UploaderCos{
-		client: client,
-		path:   config.Path,
-		domain: config.Domain,
+		client:     client,
+		path:       config.Path,
+		url:        newURLBuilder(cosDomain(config), !config.DisableSSL),
+		overwrite:  config.Overwrite,
+		sse:        config.SSE,
+		acl:        config.ACL,
+		urlExpires: urlExpires(config.URLExpires),
 	}
 
 	return
 }
 
-func (u *UploaderCos) Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error) {
-	path = util.GenName(u.path, file.Filename, randomly)
+func (u *UploaderCos) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	// 长度未知时无法计算整体 Content-MD5，改为逐个分片读入内存后分片上传
+	if file.Size() < 0 {
+		return u.MultipartUpload(ctx, file, randomly, streamPartSize, opts...)
+	}
+
+	o := newUploadOptions(opts...)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
+	path := util.GenName(u.path, file.Name(), randomly)
+
+	policy := o.overwritePolicy(u.overwrite)
+
+	path, err = resolvePath(ctx, u.Exists, path, policy)
+	if err != nil {
+		return res, err
+	}
 
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	header := cosHeaderOptions(o, sse)
+	header.ContentLength = file.Size()
+	header.XOptionHeader = cosForbidOverwrite(header.XOptionHeader, policy != OverwriteAllow)
+	if progress != nil {
+		header.Listener = &cosProgress{tracker: progress}
+	}
+
+	// 上传的同时计算校验值，上传后与服务端返回的 crc64 比较
+	body := newChecksumReader(throttle(ctx, fd, o.RateLimiters))
+	resp, err := u.client.Object.Put(ctx, path, body, &cos.ObjectPutOptions{
+		ACLHeaderOptions:       cosACLHeader(acl),
+		ObjectPutHeaderOptions: header,
+	})
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, cosExistsErr(err)
 	}
+	checksum := body.Sum()
 
-	_, err = u.client.Object.Put(ctx, path, fd, nil)
+	if err = verifyCRC64(resp.Header.Get("x-cos-hash-crc64ecma"), checksum.CRC64); err != nil {
+		return res, err
+	}
+
+	progress.completed()
+
+	fileUrl, err := u.fileURL(ctx, path, acl)
 	if err != nil {
-		return "", "", err
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: resp.Header.Get("x-cos-version-id"),
 	}
-	fileUrl = util.Join(u.domain, path)
 
 	return
 }
@@ -73,73 +153,622 @@ func (u *UploaderCos) GetUploaderType() string {
 	return Tencent
 }
 
-func (u *UploaderCos) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error) {
+func (u *UploaderCos) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	o := newUploadOptions(opts...)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
 	// 上传路径
-	path = util.GenName(u.path, file.Filename, randomly)
+	path := util.GenName(u.path, file.Name(), randomly)
+
+	policy := o.overwritePolicy(u.overwrite)
 
-	v, _, err := u.client.Object.InitiateMultipartUpload(ctx, path, nil)
+	path, err = resolvePath(ctx, u.Exists, path, policy)
 	if err != nil {
-		return "", "", err
+		return res, err
 	}
 
 	// 获取文件信息
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
+	// 计算分块大小和分块数量
+	chunkSize = chunkSize * 1024 * 1024
+	chunks, err := newChunkReader(fd, file.Size(), int64(chunkSize))
+	if err != nil {
+		return res, err
+	}
+
+	v, _, err := u.client.Object.InitiateMultipartUpload(ctx, path, &cos.InitiateMultipartUploadOptions{
+		ACLHeaderOptions:       cosACLHeader(acl),
+		ObjectPutHeaderOptions: cosHeaderOptions(o, sse),
+	})
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, err
 	}
 
 	uploadId := v.UploadID
 
-	// 计算分块大小和分块数量
-	chunkSize = chunkSize * 1024 * 1024
-	chunks, err := util.SplitFileByPartSize(fd, file.Size, int64(chunkSize))
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	// 分块上传，同时计算整体校验值
+	w := newChecksumWriter()
+	opt := &cos.CompleteMultipartUploadOptions{
+		XOptionHeader: cosForbidOverwrite(nil, policy != OverwriteAllow),
+	}
+	for {
+		chunk, err := chunks.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_, _ = u.client.Object.AbortMultipartUpload(ctx, path, uploadId)
+			return res, err
+		}
+
+		partMD5, err := partChecksum(chunk.Buf, w)
+		if err != nil {
+			_, _ = u.client.Object.AbortMultipartUpload(ctx, path, uploadId)
+			return res, err
+		}
 
-	// 分块上传
-	opt := &cos.CompleteMultipartUploadOptions{}
-	for _, chunk := range chunks {
+		partOpt := &cos.ObjectUploadPartOptions{
+			ContentMD5:    base64.StdEncoding.EncodeToString(partMD5),
+			ContentLength: chunk.Size,
+		}
+		if sse.isSSEC() {
+			partOpt.XCosSSECustomerAglo = "AES256"
+			partOpt.XCosSSECustomerKey = sse.customerKey()
+			partOpt.XCosSSECustomerKeyMD5 = sse.customerKeyMD5()
+		}
+		if progress != nil {
+			partOpt.Listener = &cosProgress{tracker: progress}
+		}
 
-		resp, err := u.client.Object.UploadPart(ctx, path, uploadId, chunk.Number, chunk.Buf, nil)
+		resp, err := u.client.Object.UploadPart(ctx, path, uploadId, chunk.Number, throttle(ctx, chunk.Buf, o.RateLimiters), partOpt)
 		if err != nil {
 			// 报错就终止上传
 			_, _ = u.client.Object.AbortMultipartUpload(ctx, path, uploadId)
-			return "", "", errors.New("Error uploading part:" + err.Error())
+			return res, errors.New("Error uploading part:" + err.Error())
 		}
 
 		PartETag := resp.Header.Get("ETag")
 		opt.Parts = append(opt.Parts, cos.Object{
 			PartNumber: chunk.Number, ETag: PartETag},
 		)
+		progress.partCompleted(chunk.Number, 0)
 
 	}
+	checksum := w.Sum()
 
 	// 完成分片上传
-	_, _, err = u.client.Object.CompleteMultipartUpload(
+	_, resp, err := u.client.Object.CompleteMultipartUpload(
 		ctx, path, uploadId, opt,
 	)
 
 	if err != nil {
-		return "", "", errors.New("Error completing multipart upload: " + err.Error())
+		_, _ = u.client.Object.AbortMultipartUpload(ctx, path, uploadId)
+		if err = cosExistsErr(err); errors.Is(err, ObjectExistsErr) {
+			return res, err
+		}
+		return res, errors.New("Error completing multipart upload: " + err.Error())
+	}
+
+	if err = verifyCRC64(resp.Header.Get("x-cos-hash-crc64ecma"), checksum.CRC64); err != nil {
+		return res, err
 	}
 
-	fileUrl = util.Join(u.domain, path)
+	progress.completed()
+
+	fileUrl, err := u.fileURL(ctx, path, acl)
+	if err != nil {
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: resp.Header.Get("x-cos-version-id"),
+	}
 
 	return
 }
 
-func (u *UploaderCos) DeleteObjects(ctx context.Context, path []string) error {
-	var obs []cos.Object
-	for _, v := range path {
-		obs = append(obs, cos.Object{Key: v})
+func (u *UploaderCos) Exists(ctx context.Context, path string) (bool, error) {
+	return u.client.Object.IsExist(ctx, path)
+}
+
+func (u *UploaderCos) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	rangeHeader, err := newObjectOptions(opts...).rangeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &cos.ObjectGetOptions{Range: rangeHeader}
+	if u.sse.isSSEC() {
+		opt.XCosSSECustomerAglo = "AES256"
+		opt.XCosSSECustomerKey = u.sse.customerKey()
+		opt.XCosSSECustomerKeyMD5 = u.sse.customerKeyMD5()
+	}
+
+	resp, err := u.client.Object.Get(ctx, path, opt, cosVersionID(opts)...)
+	if err != nil {
+		return nil, cosNotFoundErr(err)
+	}
+
+	return resp.Body, nil
+}
+
+func (u *UploaderCos) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	resp, err := u.client.Object.Head(ctx, path, u.headOptions(), cosVersionID(opts)...)
+	if err != nil {
+		return ObjectInfo{}, cosNotFoundErr(err)
+	}
+
+	return headerObjectInfo(path, resp.Header, "x-cos-meta-"), nil
+}
+
+// headOptions SSE-C 对象读取属性时需提供密钥
+func (u *UploaderCos) headOptions() *cos.ObjectHeadOptions {
+	if !u.sse.isSSEC() {
+		return nil
+	}
+
+	return &cos.ObjectHeadOptions{
+		XCosSSECustomerAglo:   "AES256",
+		XCosSSECustomerKey:    u.sse.customerKey(),
+		XCosSSECustomerKeyMD5: u.sse.customerKeyMD5(),
+	}
+}
+
+// cosVersionID cos 通过可变参数指定版本号
+func cosVersionID(opts []ObjectOption) []string {
+	if o := newObjectOptions(opts...); o.VersionID != "" {
+		return []string{o.VersionID}
+	}
+
+	return nil
+}
+
+// cosProgress 适配 cos 的进度回调
+type cosProgress consumedTracker
+
+func (p *cosProgress) ProgressChangedCallback(event *cos.ProgressEvent) {
+	(*consumedTracker)(p).update(event.ConsumedBytes)
+}
+
+func cosNotFoundErr(err error) error {
+	if cos.IsNotFoundError(err) {
+		return ObjectNotFoundErr
+	}
+
+	var respErr *cos.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return InvalidRangeErr
+	}
+
+	return err
+}
+
+// cosForbidOverwrite 非覆盖策略下设置 x-cos-forbid-overwrite，由服务端保证不覆盖已有对象
+func cosForbidOverwrite(header *http.Header, forbid bool) *http.Header {
+	if !forbid {
+		return header
+	}
+
+	if header == nil {
+		header = &http.Header{}
+	}
+	header.Set("x-cos-forbid-overwrite", "true")
+
+	return header
+}
+
+// cosExistsErr 禁止覆盖时对象已存在返回 FileAlreadyExists
+func cosExistsErr(err error) error {
+	var respErr *cos.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Code == "FileAlreadyExists" {
+		return ObjectExistsErr
+	}
+
+	return err
+}
+
+// cosHeaderOptions 将上传选项及服务端加密配置转换为 cos 请求头
+func cosHeaderOptions(o *UploadOptions, sse *ServerSideEncryption) *cos.ObjectPutHeaderOptions {
+	header := &cos.ObjectPutHeaderOptions{
+		ContentType:        o.ContentType,
+		CacheControl:       o.CacheControl,
+		ContentDisposition: o.ContentDisposition,
+		ContentEncoding:    o.ContentEncoding,
+	}
+
+	if len(o.Metadata) > 0 {
+		meta := make(http.Header, len(o.Metadata))
+		for k, v := range o.Metadata {
+			meta.Set("x-cos-meta-"+k, v)
+		}
+		header.XCosMetaXXX = &meta
+	}
+
+	if sse != nil {
+		switch sse.Algorithm {
+		case SSEKMS:
+			header.XCosServerSideEncryption = "cos/kms"
+			if sse.KMSKeyID != "" {
+				header.XOptionHeader = &http.Header{}
+				header.XOptionHeader.Set("x-cos-server-side-encryption-cos-kms-key-id", sse.KMSKeyID)
+			}
+		case SSEC:
+			header.XCosSSECustomerAglo = "AES256"
+			header.XCosSSECustomerKey = sse.customerKey()
+			header.XCosSSECustomerKeyMD5 = sse.customerKeyMD5()
+		default:
+			header.XCosServerSideEncryption = "AES256"
+		}
+	}
+
+	if len(o.Tags) > 0 {
+		if header.XOptionHeader == nil {
+			header.XOptionHeader = &http.Header{}
+		}
+		header.XOptionHeader.Set("x-cos-tagging", encodeTags(o.Tags))
+	}
+
+	return header
+}
+
+// cosACLHeader ACLDefault 时不设置 x-cos-acl，继承存储桶权限
+func cosACLHeader(acl ACL) *cos.ACLHeaderOptions {
+	if acl == ACLDefault {
+		return nil
+	}
+
+	return &cos.ACLHeaderOptions{XCosACL: string(acl)}
+}
+
+// cosDomain 未配置 Domain 时使用 EndPoint，即存储桶的访问域名
+func cosDomain(config UploaderCosConfig) string {
+	if config.Domain != "" {
+		return config.Domain
 	}
 
-	opt := &cos.ObjectDeleteMultiOptions{
-		Objects: obs,
-		Quiet:   true,
+	return config.EndPoint
+}
+
+// fileURL 私有对象返回带有效期的签名 URL
+func (u *UploaderCos) fileURL(ctx context.Context, path string, acl ACL) (string, error) {
+	if acl != ACLPrivate {
+		return u.url.build(path), nil
 	}
 
-	_, _, err := u.client.Object.DeleteMulti(ctx, opt)
+	signed, err := u.client.Object.GetPresignedURL2(ctx, http.MethodGet, path, u.urlExpires, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return signed.String(), nil
+}
 
+func (u *UploaderCos) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
+	for _, chunk := range chunkPaths(path, maxDeleteBatch) {
+		objects := make([]cos.Object, 0, len(chunk))
+		for _, v := range chunk {
+			objects = append(objects, cos.Object{Key: v})
+		}
+
+		opt := &cos.ObjectDeleteMultiOptions{
+			Objects: objects,
+			Quiet:   false,
+		}
+
+		result, _, err := u.client.Object.DeleteMulti(ctx, opt)
+		if err != nil {
+			res.failAll(chunk, err)
+			continue
+		}
+
+		for _, v := range result.DeletedObjects {
+			res.Deleted = append(res.Deleted, v.Key)
+		}
+		for _, v := range result.Errors {
+			res.fail(v.Key, v.Code, deleteCodeErr(v.Code, v.Message))
+		}
+	}
+
+	return res, res.Err()
+}
+
+func (u *UploaderCos) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+func (u *UploaderCos) ListObjects(ctx context.Context, prefix string, fn func(key string) error) error {
+	opt := &cos.BucketGetOptions{
+		Prefix:  prefix,
+		MaxKeys: maxDeleteBatch,
+	}
+
+	for {
+		result, _, err := u.client.Bucket.Get(ctx, opt)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range result.Contents {
+			if err = fn(v.Key); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || len(result.Contents) == 0 {
+			return nil
+		}
+
+		// 未指定分隔符时 NextMarker 可能为空，以本页最后一个对象继续
+		opt.Marker = result.NextMarker
+		if opt.Marker == "" {
+			opt.Marker = result.Contents[len(result.Contents)-1].Key
+		}
+	}
+}
+
+func (u *UploaderCos) MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error {
+	acl, resp, err := u.client.Object.GetACL(ctx, src)
+	if err != nil {
+		return cosNotFoundErr(err)
+	}
+
+	if _, err = u.copyObject(ctx, src, "", dst, newObjectOptions(opts...).NoOverwrite); err != nil {
+		return err
+	}
+
+	// 复制不保留对象的访问权限，default 表示继承存储桶
+	if resp.Header.Get("x-cos-acl") != "default" {
+		if _, err = u.client.Object.PutACL(ctx, dst, &cos.ObjectPutACLOptions{Body: acl}); err != nil {
+			return err
+		}
+	}
+
+	_, err = u.client.Object.Delete(ctx, src)
 	return err
 }
+
+// cosMaxCopySize cos 单次复制的对象上限
+const cosMaxCopySize = 5 << 30
+
+// copyObject 服务端复制，srcVersionID 为空时复制最新版本，返回新对象的版本号
+// forbidOverwrite 时由服务端保证不覆盖已有对象，已存在时返回 ObjectExistsErr
+func (u *UploaderCos) copyObject(ctx context.Context, src, srcVersionID, dst string, forbidOverwrite bool) (string, error) {
+	var id []string
+	if srcVersionID != "" {
+		id = append(id, srcVersionID)
+	}
+
+	head, err := u.client.Object.Head(ctx, src, u.headOptions(), id...)
+	if err != nil {
+		return "", cosNotFoundErr(err)
+	}
+
+	putHeader := cosHeaderOptions(&UploadOptions{}, u.sse)
+	if head.ContentLength > cosMaxCopySize {
+		return u.multipartCopy(ctx, src, srcVersionID, dst, head, putHeader, forbidOverwrite)
+	}
+
+	putHeader.XOptionHeader = cosForbidOverwrite(putHeader.XOptionHeader, forbidOverwrite)
+
+	header := &cos.ObjectCopyHeaderOptions{
+		XCosServerSideEncryption: putHeader.XCosServerSideEncryption,
+		XOptionHeader:            putHeader.XOptionHeader,
+		XCosSSECustomerAglo:      putHeader.XCosSSECustomerAglo,
+		XCosSSECustomerKey:       putHeader.XCosSSECustomerKey,
+		XCosSSECustomerKeyMD5:    putHeader.XCosSSECustomerKeyMD5,
+		// SSE-C 对象复制时需同时提供源对象的密钥
+		XCosCopySourceSSECustomerAglo:   putHeader.XCosSSECustomerAglo,
+		XCosCopySourceSSECustomerKey:    putHeader.XCosSSECustomerKey,
+		XCosCopySourceSSECustomerKeyMD5: putHeader.XCosSSECustomerKeyMD5,
+	}
+
+	_, resp, err := u.client.Object.Copy(ctx, dst, u.copySource(src, ""), &cos.ObjectCopyOptions{ObjectCopyHeaderOptions: header}, id...)
+	if err != nil {
+		return "", cosExistsErr(cosNotFoundErr(err))
+	}
+
+	return resp.Header.Get("x-cos-version-id"), nil
+}
+
+// multipartCopy 超过单次复制上限时分片复制，重新设置源对象的属性及标签
+func (u *UploaderCos) multipartCopy(ctx context.Context, src, srcVersionID, dst string, head *cos.Response, putHeader *cos.ObjectPutHeaderOptions, forbidOverwrite bool) (string, error) {
+	var id []interface{}
+	if srcVersionID != "" {
+		id = append(id, srcVersionID)
+	}
+
+	tagging, _, err := u.client.Object.GetTagging(ctx, src, id...)
+	if err != nil {
+		return "", cosNotFoundErr(err)
+	}
+	if len(tagging.TagSet) > 0 {
+		tags := make(map[string]string, len(tagging.TagSet))
+		for _, v := range tagging.TagSet {
+			tags[v.Key] = v.Value
+		}
+		if putHeader.XOptionHeader == nil {
+			putHeader.XOptionHeader = &http.Header{}
+		}
+		putHeader.XOptionHeader.Set("x-cos-tagging", encodeTags(tags))
+	}
+
+	// XCosMetaXXX 中的请求头原样发送，同时用于设置 Content-Type 等属性
+	header := copyHeaders(head.Header, "x-cos-meta-")
+	putHeader.XCosMetaXXX = &header
+
+	v, _, err := u.client.Object.InitiateMultipartUpload(ctx, dst, &cos.InitiateMultipartUploadOptions{ObjectPutHeaderOptions: putHeader})
+	if err != nil {
+		return "", err
+	}
+
+	size := head.ContentLength
+	// 禁止覆盖由完成分片时的请求头保证
+	opt := &cos.CompleteMultipartUploadOptions{XOptionHeader: cosForbidOverwrite(nil, forbidOverwrite)}
+	for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+copyPartSize {
+		partOpt := &cos.ObjectCopyPartOptions{
+			XCosCopySourceRange: fmt.Sprintf("bytes=%d-%d", offset, offset+min(copyPartSize, size-offset)-1),
+			// SSE-C 对象复制时需同时提供源对象的密钥
+			XCosCopySourceSSECustomerAglo:   putHeader.XCosSSECustomerAglo,
+			XCosCopySourceSSECustomerKey:    putHeader.XCosSSECustomerKey,
+			XCosCopySourceSSECustomerKeyMD5: putHeader.XCosSSECustomerKeyMD5,
+		}
+
+		part, _, err := u.client.Object.CopyPart(ctx, dst, v.UploadID, n, u.copySource(src, srcVersionID), partOpt)
+		if err != nil {
+			_, _ = u.client.Object.AbortMultipartUpload(ctx, dst, v.UploadID)
+			return "", cosNotFoundErr(err)
+		}
+		opt.Parts = append(opt.Parts, cos.Object{PartNumber: n, ETag: part.ETag})
+	}
+
+	_, resp, err := u.client.Object.CompleteMultipartUpload(ctx, dst, v.UploadID, opt)
+	if err != nil {
+		_, _ = u.client.Object.AbortMultipartUpload(ctx, dst, v.UploadID)
+		return "", cosExistsErr(err)
+	}
+
+	return resp.Header.Get("x-cos-version-id"), nil
+}
+
+// copySource 复制源地址，versionID 不为空时复制指定版本
+func (u *UploaderCos) copySource(src, versionID string) string {
+	source := u.client.BaseURL.BucketURL.Host + "/" + src
+	if versionID != "" {
+		source += "?versionId=" + versionID
+	}
+
+	return source
+}
+
+func (u *UploaderCos) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	opt := &cos.BucketGetObjectVersionsOptions{
+		Prefix:  path,
+		MaxKeys: maxDeleteBatch,
+	}
+
+	var versions []ObjectVersion
+	for {
+		result, _, err := u.client.Bucket.GetObjectVersions(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range result.Version {
+			if v.Key == path {
+				lastModified, _ := time.Parse(time.RFC3339, v.LastModified)
+				versions = append(versions, ObjectVersion{
+					Path:         v.Key,
+					VersionID:    v.VersionId,
+					Size:         v.Size,
+					ETag:         strings.Trim(v.ETag, `"`),
+					IsLatest:     v.IsLatest,
+					LastModified: lastModified,
+				})
+			}
+		}
+		for _, v := range result.DeleteMarker {
+			if v.Key == path {
+				lastModified, _ := time.Parse(time.RFC3339, v.LastModified)
+				versions = append(versions, ObjectVersion{
+					Path:           v.Key,
+					VersionID:      v.VersionId,
+					IsLatest:       v.IsLatest,
+					IsDeleteMarker: true,
+					LastModified:   lastModified,
+				})
+			}
+		}
+
+		// 下一页已不是该对象时停止
+		if !result.IsTruncated || result.NextKeyMarker != path {
+			break
+		}
+		opt.KeyMarker, opt.VersionIdMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (u *UploaderCos) DeleteVersion(ctx context.Context, path, versionID string) error {
+	_, err := u.client.Object.Delete(ctx, path, &cos.ObjectDeleteOptions{VersionId: versionID})
+	return err
+}
+
+func (u *UploaderCos) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	return u.copyObject(ctx, path, versionID, path, false)
+}
+
+func (u *UploaderCos) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Tagging: true, ACL: true}
+}
+
+func (u *UploaderCos) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	result, _, err := u.client.Object.GetTagging(ctx, path)
+	if err != nil {
+		return nil, cosNotFoundErr(err)
+	}
+
+	tags := make(map[string]string, len(result.TagSet))
+	for _, v := range result.TagSet {
+		tags[v.Key] = v.Value
+	}
+
+	return tags, nil
+}
+
+func (u *UploaderCos) SetObjectTags(ctx context.Context, path string, tags map[string]string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	opt := &cos.ObjectPutTaggingOptions{TagSet: make([]cos.ObjectTaggingTag, 0, len(tags))}
+	for k, v := range tags {
+		opt.TagSet = append(opt.TagSet, cos.ObjectTaggingTag{Key: k, Value: v})
+	}
+
+	_, err := u.client.Object.PutTagging(ctx, path, opt)
+
+	return cosNotFoundErr(err)
+}
+
+func (u *UploaderCos) DeleteObjectTags(ctx context.Context, path string) error {
+	_, err := u.client.Object.DeleteTagging(ctx, path)
+
+	return cosNotFoundErr(err)
+}
+
+func (u *UploaderCos) SetACL(ctx context.Context, path string, acl ACL) error {
+	if err := acl.validate(); err != nil {
+		return err
+	}
+
+	_, err := u.client.Object.PutACL(ctx, path, &cos.ObjectPutACLOptions{Header: cosACLHeader(acl)})
+
+	return cosNotFoundErr(err)
+}
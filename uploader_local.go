@@ -2,11 +2,16 @@ package file_storage
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/qiuyier/file-storage/pkg/util"
 	"io"
-	"mime/multipart"
+	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -17,22 +22,73 @@ import (
 type UploaderLocalConfig struct {
 	LocalPath string
 	Domain    string
+	Overwrite OverwritePolicy
+	// Versioning 模拟多版本，覆盖或删除时将原文件移动到 LocalPath/.versions/<相对路径>/<版本号>
+	Versioning bool
+	// ACL 默认访问权限，为空时视为公共读
+	ACL ACL
+	// SignKey 私有对象签名 URL 的密钥，使用 ACLPrivate 时必填
+	SignKey string
+	// URLExpires 私有对象签名 URL 的有效期，默认 1 小时
+	URLExpires time.Duration
+	// PublicPath LocalPath 对外访问的路径前缀，FileUrl 为 Domain + PublicPath + 相对 LocalPath 的路径
+	PublicPath string
+	// DisableSSL Domain 未带协议头时使用 http，默认 https
+	DisableSSL bool
 }
 
 type UploaderLocal struct {
 	localPath string
-	domain    string
+	// root LocalPath 的绝对路径，所有读写删除操作都限定在该目录内
+	root       string
+	url        urlBuilder
+	publicPath string
+	overwrite  OverwritePolicy
+	versioning bool
+	acl        ACL
+	signKey    string
+	urlExpires time.Duration
 }
 
 func NewUploaderLocal(config UploaderLocalConfig) (uploader *UploaderLocal, err error) {
+	localPath := util.TrimRight(config.LocalPath, string(os.PathSeparator))
+
+	root, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, errors.New("abs path " + localPath + ", err: " + err.Error())
+	}
+
 	uploader = &UploaderLocal{
-		localPath: util.TrimRight(config.LocalPath, string(os.PathSeparator)),
-		domain:    config.Domain,
+		localPath:  localPath,
+		root:       root,
+		url:        newURLBuilder(config.Domain, !config.DisableSSL, config.PublicPath),
+		publicPath: strings.Trim(config.PublicPath, "/"),
+		overwrite:  config.Overwrite,
+		versioning: config.Versioning,
+		acl:        config.ACL,
+		signKey:    config.SignKey,
+		urlExpires: urlExpires(config.URLExpires),
 	}
 	return
 }
 
-func (u *UploaderLocal) Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error) {
+func (u *UploaderLocal) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	o := newUploadOptions(opts...)
+	if o.SSE != nil {
+		return res, fmt.Errorf("%w: local driver does not support server-side encryption", UnsupportedOptionErr)
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+	if err = u.checkACL(acl); err != nil {
+		return res, err
+	}
+
 	nowDate := time.Now().Format(time.DateOnly)
 
 	// 文件保存路径
@@ -43,41 +99,440 @@ func (u *UploaderLocal) Upload(ctx context.Context, file *multipart.FileHeader,
 		// 不存在则创建文件夹
 		if err = os.MkdirAll(dirPath, os.ModePerm); err != nil {
 			err = errors.New("create dir " + dirPath + ", err: " + err.Error())
-			return "", "", err
+			return res, err
 		}
 	} else if !isDir(dirPath) {
 		// 路径存在但不为文件夹时
-		return "", "", NotDirErr
+		return res, NotDirErr
 	}
 
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
+	name := util.GenFileName(file.Name(), randomly)
+
+	filePath, err := u.resolve(filepath.Join(dirPath, name))
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, err
 	}
 
-	name := genName(file.Filename, randomly)
+	policy := o.overwritePolicy(u.overwrite)
+	if policy == OverwriteFail && exists(filePath) {
+		return res, ObjectExistsErr
+	}
 
-	filePath := util.Join(dirPath, name)
-	newFile, err := create(filePath)
+	// 先写入同目录下的临时文件，校验通过后再放到目标路径，失败时不影响已有文件
+	tmp, err := os.CreateTemp(dir(filePath), localTempPrefix+"*"+localTempSuffix)
 	if err != nil {
-		return "", "", err
+		return res, errors.New("create temp file, err: " + err.Error())
 	}
-	defer newFile.Close()
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	if _, err = io.Copy(newFile, fd); err != nil {
-		err = errors.New("copy file " + filePath + "err: " + err.Error())
-		return "", "", err
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	// 写入的同时计算校验值
+	w := newChecksumWriter()
+	writers := []io.Writer{tmp, w}
+	if progress != nil {
+		writers = append(writers, progress)
+	}
+
+	if _, err = io.Copy(io.MultiWriter(writers...), throttle(ctx, fd, o.RateLimiters)); err != nil {
+		return res, fmt.Errorf("copy file %s, err: %w", filePath, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return res, errors.New("close file " + tmp.Name() + ", err: " + err.Error())
+	}
+	checksum := w.Sum()
+
+	// 写入成功后才将原文件保留为历史版本
+	var versionID string
+	if u.versioning {
+		versionID = newLocalVersionID()
+		if policy == OverwriteAllow && exists(filePath) {
+			if err = u.archive(filePath); err != nil {
+				return res, err
+			}
+		}
 	}
 
-	return filePath, util.Join(u.domain, filePath), nil
+	if filePath, err = u.placeFile(tmp.Name(), filePath, policy); err != nil {
+		return res, err
+	}
+
+	if err = u.writeMeta(filePath, localMeta{
+		ContentType:        o.ContentType,
+		CacheControl:       o.CacheControl,
+		ContentDisposition: o.ContentDisposition,
+		ContentEncoding:    o.ContentEncoding,
+		Metadata:           o.Metadata,
+		MD5:                checksum.MD5,
+		VersionID:          versionID,
+		Tags:               o.Tags,
+		ACL:                acl,
+	}); err != nil {
+		return res, err
+	}
+
+	progress.completed()
+
+	fileUrl, err := u.fileURL(filePath, acl)
+	if err != nil {
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      filePath,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: versionID,
+	}
+
+	return res, nil
 }
 
 func (u *UploaderLocal) GetUploaderType() string {
 	return Local
 }
 
+func (u *UploaderLocal) Exists(ctx context.Context, path string) (bool, error) {
+	path, err := u.resolve(path)
+	if err != nil {
+		return false, err
+	}
+
+	return exists(path), nil
+}
+
+func (u *UploaderLocal) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	path, err := u.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newObjectOptions(opts...)
+	if o.VersionID != "" {
+		if path, err = u.versionPath(path, o.VersionID); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ObjectNotFoundErr
+		}
+		return nil, errors.New("open file " + path + ", err: " + err.Error())
+	}
+
+	if !o.hasRange() {
+		return file, nil
+	}
+
+	return localRangeReader(file, o)
+}
+
+// localRangeReader 通过 SectionReader 读取文件的指定范围
+func localRangeReader(file *os.File, o *ObjectOptions) (io.ReadCloser, error) {
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, errors.New("stat file " + file.Name() + ", err: " + err.Error())
+	}
+
+	if o.Offset < 0 || o.Offset >= info.Size() {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: offset %d, size %d", InvalidRangeErr, o.Offset, info.Size())
+	}
+
+	length := info.Size() - o.Offset
+	if o.Length > 0 {
+		length = min(length, o.Length)
+	}
+
+	return readCloser{Reader: io.NewSectionReader(file, o.Offset, length), Closer: file}, nil
+}
+
+func (u *UploaderLocal) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	path, err := u.resolve(path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	// file 为实际读取的文件，读取历史版本时位于版本目录
+	file := path
+	if o := newObjectOptions(opts...); o.VersionID != "" {
+		if file, err = u.versionPath(path, o.VersionID); err != nil {
+			return ObjectInfo{}, err
+		}
+	}
+
+	stat, err := os.Stat(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ObjectInfo{}, ObjectNotFoundErr
+		}
+		return ObjectInfo{}, errors.New("stat file " + path + ", err: " + err.Error())
+	}
+	if stat.IsDir() {
+		return ObjectInfo{}, ObjectNotFoundErr
+	}
+
+	meta, err := u.readMeta(file)
+	if err != nil {
+		return ObjectInfo{}, errors.New("read meta " + file + ", err: " + err.Error())
+	}
+
+	info := ObjectInfo{
+		Path:            path,
+		Size:            stat.Size(),
+		ETag:            localETag(meta, stat),
+		ContentType:     meta.ContentType,
+		ContentEncoding: meta.ContentEncoding,
+		LastModified:    stat.ModTime(),
+		Metadata:        lowerMetadata(meta.Metadata, ""),
+	}
+	if u.versioning || file != path {
+		info.VersionID = localVersionID(meta, stat)
+	}
+
+	return info, nil
+}
+
+// localETag 没有旁路文件时以修改时间和大小作为 ETag
+func localETag(meta localMeta, stat os.FileInfo) string {
+	if meta.MD5 != "" {
+		return meta.MD5
+	}
+
+	return fmt.Sprintf("%x-%x", stat.ModTime().UnixNano(), stat.Size())
+}
+
+// resolve 校验路径位于 LocalPath 之内，拒绝 ../ 及软链接等越界访问，返回清理后的路径
+func (u *UploaderLocal) resolve(path string) (string, error) {
+	path = filepath.Clean(path)
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.New("abs path " + path + ", err: " + err.Error())
+	}
+
+	if !within(u.root, abs) {
+		return "", PathEscapeErr
+	}
+	if u.reserved(abs) {
+		return "", ReservedPathErr
+	}
+
+	// 按最深的已存在路径的真实路径再校验一次，防止通过软链接文件或目录跳出根目录
+	realRoot, err := filepath.EvalSymlinks(u.root)
+	if err != nil {
+		realRoot = u.root
+	}
+
+	for existing := abs; ; existing = filepath.Dir(existing) {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if real != realRoot && !within(realRoot, real) {
+				return "", PathEscapeErr
+			}
+			break
+		}
+
+		// 路径存在却无法解析，如指向不存在目标的软链接，写入时会在目标位置创建文件
+		if _, err = os.Lstat(existing); err == nil {
+			return "", PathEscapeErr
+		}
+
+		if filepath.Dir(existing) == existing {
+			break
+		}
+	}
+
+	return path, nil
+}
+
+// reserved 元数据及历史版本目录由驱动管理，不能作为对象路径读写
+func (u *UploaderLocal) reserved(abs string) bool {
+	rel, err := filepath.Rel(u.root, abs)
+	if err != nil {
+		return false
+	}
+
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+
+	return first == localMetaDir || first == localVersionsDir
+}
+
+// isTempFile 判断是否为上传中的临时文件
+func isTempFile(d fs.DirEntry) bool {
+	return !d.IsDir() && strings.HasPrefix(d.Name(), localTempPrefix) && strings.HasSuffix(d.Name(), localTempSuffix)
+}
+
+// within 判断 path 是否为 root 下的子路径，root 自身不算在内
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// placeFile 按覆盖策略将临时文件放到目标路径，非覆盖策略下通过硬链接保证不会覆盖已有文件
+func (u *UploaderLocal) placeFile(tmp, path string, policy OverwritePolicy) (string, error) {
+	if policy == OverwriteAllow {
+		if err := os.Rename(tmp, path); err != nil {
+			return "", errors.New("rename " + tmp + ", err: " + err.Error())
+		}
+		return path, nil
+	}
+
+	candidate := path
+	for i := 0; i <= maxRenameAttempts; i++ {
+		if i > 0 {
+			candidate = util.SuffixName(path, i)
+		}
+
+		err := os.Link(tmp, candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !os.IsExist(err) {
+			return "", errors.New("link " + candidate + ", err: " + err.Error())
+		}
+		if policy == OverwriteFail {
+			return "", ObjectExistsErr
+		}
+	}
+
+	return "", ObjectExistsErr
+}
+
+// localTempPrefix、localTempSuffix 上传中的临时文件名，遍历时跳过
+const (
+	localTempPrefix = ".upload-"
+	localTempSuffix = ".tmp"
+)
+
+// localMetaDir 元数据目录，位于 LocalPath 下，按对象的相对路径保存 json 旁路文件，不与对象共用命名空间
+const localMetaDir = ".meta"
+
+// localMeta 本地驱动的对象元数据
+type localMeta struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	MD5                string            `json:"md5,omitempty"`
+	VersionID          string            `json:"version_id,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	ACL                ACL               `json:"acl,omitempty"`
+}
+
+// metaPath 对象的元数据文件路径，历史版本的元数据同样按其在 .versions 下的相对路径保存
+func (u *UploaderLocal) metaPath(path string) (string, error) {
+	rel, err := u.relPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(u.root, localMetaDir, rel) + ".json", nil
+}
+
+func (u *UploaderLocal) writeMeta(path string, meta localMeta) error {
+	p, err := u.metaPath(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	if err = mkdir(dir(p)); err != nil {
+		return err
+	}
+	if err = os.WriteFile(p, data, 0666); err != nil {
+		return errors.New("write meta " + path + ", err: " + err.Error())
+	}
+
+	return nil
+}
+
+func (u *UploaderLocal) readMeta(path string) (meta localMeta, err error) {
+	p, err := u.metaPath(path)
+	if err != nil {
+		return meta, err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, err
+	}
+
+	err = json.Unmarshal(data, &meta)
+
+	return
+}
+
+// removeMeta 删除元数据文件及留下的空目录
+func (u *UploaderLocal) removeMeta(path string) {
+	p, err := u.metaPath(path)
+	if err != nil {
+		return
+	}
+
+	_ = os.Remove(p)
+	_ = os.Remove(dir(p))
+}
+
+// moveMeta 元数据随对象移动，目标原有的元数据被替换
+func (u *UploaderLocal) moveMeta(src, dst string) {
+	srcMeta, err := u.metaPath(src)
+	if err != nil {
+		return
+	}
+	dstMeta, err := u.metaPath(dst)
+	if err != nil {
+		return
+	}
+
+	_ = os.Remove(dstMeta)
+	if exists(srcMeta) && mkdir(dir(dstMeta)) == nil {
+		_ = os.Rename(srcMeta, dstMeta)
+		_ = os.Remove(dir(srcMeta))
+	}
+}
+
+// moveMetaDir 目录移动时，目录下所有文件的元数据随之移动
+func (u *UploaderLocal) moveMetaDir(src, dst string) {
+	srcMeta, err := u.metaPath(src)
+	if err != nil {
+		return
+	}
+	dstMeta, err := u.metaPath(dst)
+	if err != nil {
+		return
+	}
+
+	srcMeta, dstMeta = strings.TrimSuffix(srcMeta, ".json"), strings.TrimSuffix(dstMeta, ".json")
+	if exists(srcMeta) && mkdir(dir(dstMeta)) == nil {
+		_ = os.Rename(srcMeta, dstMeta)
+	}
+}
+
 // 代码源于 gf 框架
 func exists(path string) bool {
 	if stat, err := os.Stat(path); stat != nil && !os.IsNotExist(err) {
@@ -137,19 +592,6 @@ func realPath(path string) string {
 	return p
 }
 
-func genName(fileName string, randomly bool) string {
-	name := filepath.Base(fileName)
-
-	// 如果设置随机名，则重新命名
-	if randomly {
-		random := util.RandomlyName(6)
-		name = strings.ToLower(strconv.FormatInt(time.Now().UnixNano(), 36) + random)
-		name = fmt.Sprintf("%s%s", name, util.Ext(fileName))
-	}
-
-	return name
-}
-
 func checkIfFolderHasFiles(folderPath string) bool {
 	// 读取目录
 	d, err := os.ReadDir(folderPath)
@@ -165,39 +607,628 @@ func checkIfFolderHasFiles(folderPath string) bool {
 	return false
 }
 
-func (u *UploaderLocal) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error) {
-	return "", "", errors.New("not support multipart upload")
+func (u *UploaderLocal) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	return res, errors.New("not support multipart upload")
 }
 
-func (u *UploaderLocal) DeleteObjects(ctx context.Context, path []string) error {
-	var (
-		undeleteFile []string
-		err          error
-	)
-
+func (u *UploaderLocal) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
 	for _, v := range path {
-		if exists(v) {
-			if isDir(v) {
-				err = os.RemoveAll(v)
+		p, err := u.resolve(v)
+		if err != nil {
+			res.fail(v, "", err)
+			continue
+		}
+
+		if exists(p) {
+			if isDir(p) {
+				err = u.removeDir(p)
 			} else {
-				err = os.Remove(v)
+				err = u.removeFile(p)
+			}
 
-				// 文件夹为空时删除文件夹
-				if !checkIfFolderHasFiles(dir(v)) {
-					_ = os.RemoveAll(dir(v))
+			if err != nil {
+				if os.IsPermission(err) {
+					err = fmt.Errorf("%w: %v", AccessDeniedErr, err)
 				}
+				res.fail(v, "", err)
+				continue
 			}
+		}
 
-			if err != nil {
-				undeleteFile = append(undeleteFile, v)
+		res.Deleted = append(res.Deleted, v)
+	}
+
+	return res, res.Err()
+}
+
+// removeFile 删除文件及元数据，开启多版本时保留为历史版本
+func (u *UploaderLocal) removeFile(path string) (err error) {
+	if u.versioning {
+		err = u.archive(path)
+	} else {
+		err = os.Remove(path)
+		u.removeMeta(path)
+	}
+
+	u.removeEmptyDir(dir(path))
+
+	return err
+}
+
+// removeDir 逐个删除目录下的文件，保证元数据被清理及开启多版本时保留历史版本，再由深到浅删除空目录
+func (u *UploaderLocal) removeDir(path string) error {
+	var files, dirs []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range files {
+		if err = u.removeFile(v); err != nil {
+			return err
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+
+	return nil
+}
+
+// removeEmptyDir 文件夹为空时删除文件夹，根目录保留
+func (u *UploaderLocal) removeEmptyDir(d string) {
+	if !checkIfFolderHasFiles(d) {
+		if _, rootErr := u.resolve(d); rootErr == nil {
+			_ = os.Remove(d)
+		}
+	}
+}
+
+// DeletePrefix 前缀按路径字符串匹配，以 / 结尾时只匹配该目录下的文件，删除后清理留下的空目录
+func (u *UploaderLocal) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (res DeleteResult, err error) {
+	res, err = deletePrefix(ctx, u, prefix, dryRun)
+	if err != nil || dryRun {
+		return
+	}
+
+	// 由深到浅删除，非空目录删除失败时忽略
+	var dirs []string
+	_ = u.walkPrefix(prefix, true, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+
+	return
+}
+
+func (u *UploaderLocal) ListObjects(ctx context.Context, prefix string, fn func(key string) error) error {
+	// 先完成遍历再回调，避免边删除边遍历
+	var paths []string
+	err := u.walkPrefix(prefix, false, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+
+		paths = append(paths, path)
+		return ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range paths {
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkPrefix 遍历匹配前缀的文件和目录，withBase 为 true 且前缀以 / 结尾时包含该目录本身
+func (u *UploaderLocal) walkPrefix(prefix string, withBase bool, fn func(path string, d fs.DirEntry) error) error {
+	p, err := u.resolve(prefix)
+	if err != nil {
+		return err
+	}
+
+	base, match := filepath.Dir(p), p
+	if strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, string(os.PathSeparator)) {
+		base, match = p, p+string(os.PathSeparator)
+	}
+
+	return filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if path == base {
+			if withBase && path == p {
+				return fn(path, d)
 			}
+			return nil
+		}
+
+		if !strings.HasPrefix(path, match) || u.reserved(path) || isTempFile(d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return fn(path, d)
+	})
+}
+
+// MoveObject WithNoOverwrite 时先创建硬链接再删除源文件，目标已存在时创建失败，保证不覆盖；
+// 目录无法创建硬链接，先检查目标不存在再重命名
+func (u *UploaderLocal) MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error {
+	src, err := u.resolve(src)
+	if err != nil {
+		return err
+	}
+
+	dst, err = u.resolve(dst)
+	if err != nil {
+		return err
+	}
+
+	if !exists(src) {
+		return ObjectNotFoundErr
+	}
+
+	if err = mkdir(dir(dst)); err != nil {
+		return err
+	}
+
+	srcIsDir := isDir(src)
+	if newObjectOptions(opts...).NoOverwrite && !srcIsDir {
+		if err = os.Link(src, dst); err != nil {
+			if os.IsExist(err) {
+				return ObjectExistsErr
+			}
+			return errors.New("link " + src + ", err: " + err.Error())
+		}
+		if err = os.Remove(src); err != nil {
+			return errors.New("remove " + src + ", err: " + err.Error())
+		}
+	} else {
+		if newObjectOptions(opts...).NoOverwrite && exists(dst) {
+			return ObjectExistsErr
+		}
+		if err = os.Rename(src, dst); err != nil {
+			return errors.New("rename " + src + ", err: " + err.Error())
+		}
+	}
+
+	if srcIsDir {
+		u.moveMetaDir(src, dst)
+	} else {
+		u.moveMeta(src, dst)
+	}
+
+	u.removeEmptyDir(dir(src))
+
+	return nil
+}
+
+// localVersionsDir 历史版本目录，位于 LocalPath 下
+const localVersionsDir = ".versions"
+
+// newLocalVersionID 按时间生成版本号，定长便于排序
+func newLocalVersionID() string {
+	return fmt.Sprintf("%020d", time.Now().UnixNano())
+}
+
+// localVersionID 未开启多版本时上传的文件没有版本号，以修改时间代替
+func localVersionID(meta localMeta, stat os.FileInfo) string {
+	if meta.VersionID != "" {
+		return meta.VersionID
+	}
+
+	return fmt.Sprintf("%020d", stat.ModTime().UnixNano())
+}
+
+// versionsDir 对象的历史版本目录
+func (u *UploaderLocal) versionsDir(path string) (string, error) {
+	rel, err := u.relPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(u.root, localVersionsDir, rel), nil
+}
+
+// relPath 相对 LocalPath 的路径
+func (u *UploaderLocal) relPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.New("abs path " + path + ", err: " + err.Error())
+	}
+
+	rel, err := filepath.Rel(u.root, abs)
+	if err != nil {
+		return "", errors.New("rel path " + path + ", err: " + err.Error())
+	}
+
+	return rel, nil
+}
+
+// versionPath 指定版本的文件路径，当前版本返回 path 本身
+func (u *UploaderLocal) versionPath(path, versionID string) (string, error) {
+	// 版本号作为文件名，拒绝包含路径分隔符的版本号
+	if versionID == "." || versionID == ".." || filepath.Base(versionID) != versionID {
+		return "", ObjectNotFoundErr
+	}
+
+	if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+		if meta, err := u.readMeta(path); err == nil && localVersionID(meta, stat) == versionID {
+			return path, nil
+		}
+	}
+
+	d, err := u.versionsDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	p := filepath.Join(d, versionID)
+	if !exists(p) {
+		return "", ObjectNotFoundErr
+	}
+
+	return p, nil
+}
+
+// archive 将当前文件移动到历史版本目录
+func (u *UploaderLocal) archive(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return errors.New("stat file " + path + ", err: " + err.Error())
+	}
+
+	meta, err := u.readMeta(path)
+	if err != nil {
+		return errors.New("read meta " + path + ", err: " + err.Error())
+	}
+
+	d, err := u.versionsDir(path)
+	if err != nil {
+		return err
+	}
+	if err = mkdir(d); err != nil {
+		return err
+	}
+
+	p := filepath.Join(d, localVersionID(meta, stat))
+	if err = os.Rename(path, p); err != nil {
+		return errors.New("rename " + path + ", err: " + err.Error())
+	}
+	u.moveMeta(path, p)
+
+	return nil
+}
+
+func (u *UploaderLocal) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	path, err := u.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ObjectVersion
+	appendVersion := func(file string, latest bool) error {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return errors.New("stat file " + file + ", err: " + err.Error())
+		}
+
+		meta, err := u.readMeta(file)
+		if err != nil {
+			return errors.New("read meta " + file + ", err: " + err.Error())
+		}
+
+		versions = append(versions, ObjectVersion{
+			Path:         path,
+			VersionID:    localVersionID(meta, stat),
+			Size:         stat.Size(),
+			ETag:         localETag(meta, stat),
+			IsLatest:     latest,
+			LastModified: stat.ModTime(),
+		})
+		return nil
+	}
+
+	if exists(path) && !isDir(path) {
+		if err = appendVersion(path, true); err != nil {
+			return nil, err
+		}
+	}
+
+	d, err := u.versionsDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(d)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New("read dir " + d + ", err: " + err.Error())
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err = appendVersion(filepath.Join(d, entry.Name()), false); err != nil {
+			return nil, err
+		}
+	}
+
+	sortVersions(versions)
 
+	return versions, nil
+}
+
+func (u *UploaderLocal) DeleteVersion(ctx context.Context, path, versionID string) error {
+	path, err := u.resolve(path)
+	if err != nil {
+		return err
+	}
+
+	p, err := u.versionPath(path, versionID)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(p); err != nil {
+		return errors.New("remove " + p + ", err: " + err.Error())
+	}
+	u.removeMeta(p)
+
+	if p != path {
+		_ = os.Remove(dir(p))
+		return nil
+	}
+
+	// 删除的是当前版本时，最新的历史版本成为当前版本
+	if err = u.promote(ctx, path); err != nil {
+		return err
+	}
+	u.removeEmptyDir(dir(path))
+
+	return nil
+}
+
+// promote 将最新的历史版本移回 path，没有历史版本时不做处理
+func (u *UploaderLocal) promote(ctx context.Context, path string) error {
+	versions, err := u.ListVersions(ctx, path)
+	if err != nil || len(versions) == 0 {
+		return err
+	}
+
+	latest := versions[0]
+	for _, v := range versions[1:] {
+		if v.LastModified.Equal(latest.LastModified) && v.VersionID > latest.VersionID {
+			latest = v
 		}
 	}
 
-	if len(undeleteFile) > 0 {
-		err = errors.New(fmt.Sprintf("file %s not deleted", strings.Join(undeleteFile, ", ")))
+	d, err := u.versionsDir(path)
+	if err != nil {
+		return err
+	}
+
+	p := filepath.Join(d, latest.VersionID)
+	if err = os.Rename(p, path); err != nil {
+		return errors.New("rename " + p + ", err: " + err.Error())
+	}
+	u.moveMeta(p, path)
+	_ = os.Remove(d)
+
+	return nil
+}
+
+func (u *UploaderLocal) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	path, err := u.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	src, err := u.versionPath(path, versionID)
+	if err != nil {
+		return "", err
+	}
+
+	// 已是当前版本
+	if src == path {
+		return versionID, nil
+	}
+
+	meta, err := u.readMeta(src)
+	if err != nil {
+		return "", errors.New("read meta " + src + ", err: " + err.Error())
+	}
+
+	if exists(path) {
+		if err = u.archive(path); err != nil {
+			return "", err
+		}
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return "", errors.New("open file " + src + ", err: " + err.Error())
+	}
+	defer srcFile.Close()
+
+	dstFile, err := create(path)
+	if err != nil {
+		return "", err
+	}
+	defer dstFile.Close()
+
+	if _, err = io.Copy(dstFile, srcFile); err != nil {
+		return "", errors.New("copy file " + path + ", err: " + err.Error())
+	}
+
+	meta.VersionID = newLocalVersionID()
+	if err = u.writeMeta(path, meta); err != nil {
+		return "", err
+	}
+
+	return meta.VersionID, nil
+}
+
+func (u *UploaderLocal) Capabilities() Capabilities {
+	return Capabilities{Versioning: u.versioning, Tagging: true, ACL: true}
+}
+
+func (u *UploaderLocal) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	path, err := u.resolveObject(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := u.readMeta(path)
+	if err != nil {
+		return nil, errors.New("read meta " + path + ", err: " + err.Error())
+	}
+
+	tags := make(map[string]string, len(meta.Tags))
+	for k, v := range meta.Tags {
+		tags[k] = v
+	}
+
+	return tags, nil
+}
+
+func (u *UploaderLocal) SetObjectTags(ctx context.Context, path string, tags map[string]string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	return u.updateMeta(path, func(meta *localMeta) {
+		meta.Tags = tags
+	})
+}
+
+func (u *UploaderLocal) DeleteObjectTags(ctx context.Context, path string) error {
+	return u.updateMeta(path, func(meta *localMeta) {
+		meta.Tags = nil
+	})
+}
+
+// updateMeta 修改元数据旁路文件中的部分字段，其余元数据保持不变
+func (u *UploaderLocal) updateMeta(path string, fn func(meta *localMeta)) error {
+	path, err := u.resolveObject(path)
+	if err != nil {
+		return err
+	}
+
+	meta, err := u.readMeta(path)
+	if err != nil {
+		return errors.New("read meta " + path + ", err: " + err.Error())
+	}
+
+	fn(&meta)
+
+	return u.writeMeta(path, meta)
+}
+
+// resolveObject 校验路径并要求对象存在且不是目录
+func (u *UploaderLocal) resolveObject(path string) (string, error) {
+	path, err := u.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists(path) || isDir(path) {
+		return "", ObjectNotFoundErr
+	}
+
+	return path, nil
+}
+
+// SetACL 访问权限保存在元数据旁路文件中，由文件服务校验
+func (u *UploaderLocal) SetACL(ctx context.Context, path string, acl ACL) error {
+	if err := acl.validate(); err != nil {
+		return err
+	}
+	if err := u.checkACL(acl); err != nil {
+		return err
+	}
+
+	return u.updateMeta(path, func(meta *localMeta) {
+		meta.ACL = acl
+	})
+}
+
+// checkACL 私有对象需要密钥生成签名 URL
+func (u *UploaderLocal) checkACL(acl ACL) error {
+	if acl == ACLPrivate && u.signKey == "" {
+		return fmt.Errorf("%w: local driver requires SignKey for private objects", UnsupportedOptionErr)
 	}
 
 	return nil
 }
+
+// fileURL 以相对 LocalPath 的路径作为 key，私有对象在 URL 后追加过期时间和签名
+func (u *UploaderLocal) fileURL(path string, acl ACL) (string, error) {
+	rel, err := u.relPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	key := filepath.ToSlash(rel)
+	fileUrl := u.url.build(key)
+	if acl != ACLPrivate {
+		return fileUrl, nil
+	}
+
+	expires := time.Now().Add(u.urlExpires).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {localSignature(u.signKey, key, expires)},
+	}
+
+	return fileUrl + "?" + query.Encode(), nil
+}
+
+// verifySignature 校验签名 URL 的过期时间和签名
+func (u *UploaderLocal) verifySignature(key string, query url.Values) bool {
+	if u.signKey == "" {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(query.Get("signature")), []byte(localSignature(u.signKey, key, expires)))
+}
+
+// localSignature 以 HMAC-SHA256 对 key 和过期时间签名
+func localSignature(signKey, key string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(signKey))
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
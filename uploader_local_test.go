@@ -0,0 +1,584 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newFileHeader(t *testing.T, name string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var buff bytes.Buffer
+	formWriter := multipart.NewWriter(&buff)
+	formPart, err := formWriter.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = formPart.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	_ = formWriter.Close()
+
+	form, err := multipart.NewReader(&buff, formWriter.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return form.File["file"][0]
+}
+
+func newSource(t *testing.T, name string, content []byte) Source {
+	return FileHeaderSource(newFileHeader(t, name, content))
+}
+
+func TestLocalOverwritePolicy(t *testing.T) {
+	root := t.TempDir()
+	ctx := context.Background()
+
+	failUploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root, Overwrite: OverwriteFail})
+	res, err := failUploader.Upload(ctx, newSource(t, "a.txt", []byte("first")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := res.Path
+
+	if _, err = failUploader.Upload(ctx, newSource(t, "a.txt", []byte("second")), false); !errors.Is(err, ObjectExistsErr) {
+		t.Fatalf("expected ObjectExistsErr, got %v", err)
+	}
+
+	renameUploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root, Overwrite: OverwriteRename})
+	renamed, err := renameUploader.Upload(ctx, newSource(t, "a.txt", []byte("second")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(renamed.Path) != "a (1).txt" {
+		t.Fatalf("unexpected renamed path %s", renamed.Path)
+	}
+
+	overwriteUploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	if _, err = overwriteUploader.Upload(ctx, newSource(t, "a.txt", []byte("third")), false); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "third" {
+		t.Fatalf("expected overwritten content, got %q", content)
+	}
+}
+
+func TestLocalFailedOverwrite(t *testing.T) {
+	ctx := context.Background()
+
+	for _, versioning := range []bool{false, true} {
+		local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir(), Versioning: versioning})
+		res, err := local.Upload(ctx, newSource(t, "a.txt", []byte("first")), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		uploader := NewFileUploader().RegisterUploader(local).SetValidation(ValidationRules{MaxSize: 10})
+		source := NewStreamSource("a.txt", bytes.NewReader(bytes.Repeat([]byte("a"), 100)))
+		if _, err = uploader.UploadSource(ctx, source, false); !errors.Is(err, FileTooLargeErr) {
+			t.Fatalf("expected FileTooLargeErr, got %v", err)
+		}
+
+		content, err := os.ReadFile(res.Path)
+		if err != nil || string(content) != "first" {
+			t.Fatalf("versioning %v: original lost, %q, %v", versioning, content, err)
+		}
+
+		entries, _ := os.ReadDir(filepath.Dir(res.Path))
+		if len(entries) != 1 {
+			t.Fatalf("versioning %v: unexpected files %v", versioning, entries)
+		}
+	}
+}
+
+func TestLocalUploadOptions(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+
+	res, err := uploader.Upload(context.Background(), newSource(t, "report.json", []byte(`{"a":1}`)), true,
+		WithCacheControl("max-age=60"),
+		WithContentDisposition(`attachment; filename="report.json"`),
+		WithMetadata(map[string]string{"tenant": "42"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := uploader.readMeta(res.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.ContentType != "application/json" || meta.CacheControl != "max-age=60" || meta.Metadata["tenant"] != "42" {
+		t.Fatalf("unexpected meta %+v", meta)
+	}
+}
+
+func TestLocalPathEscape(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside.txt")
+	_ = os.WriteFile(outside, []byte("keep"), 0666)
+
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	ctx := context.Background()
+
+	res, err := uploader.Upload(ctx, newSource(t, "../../outside.txt", []byte("x")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := res.Path
+	if abs, _ := filepath.Abs(path); !within(root, abs) || filepath.Base(path) != "outside.txt" {
+		t.Fatalf("upload escaped root: %s", path)
+	}
+
+	for _, p := range []string{outside, filepath.Join(root, "..", "outside.txt"), root} {
+		if _, err = uploader.DeleteObjects(ctx, []string{p}); !errors.Is(err, PathEscapeErr) {
+			t.Errorf("expected delete of %s to be rejected, got %v", p, err)
+		}
+	}
+	if !exists(outside) || !exists(root) {
+		t.Fatal("file outside root was deleted")
+	}
+
+	if _, err = uploader.DeleteObjects(ctx, []string{path}); err != nil || exists(path) {
+		t.Fatalf("delete inside root failed: %v", err)
+	}
+
+	// 软链接目录下尚不存在的文件，以及指向不存在目标的软链接
+	outsideDir := filepath.Join(base, "outside")
+	_ = os.Mkdir(outsideDir, 0777)
+	_ = os.Symlink(outsideDir, filepath.Join(root, "link"))
+	_ = os.Symlink(filepath.Join(base, "missing.txt"), filepath.Join(root, "dangling"))
+
+	res, err = uploader.Upload(ctx, newSource(t, "b.txt", []byte("x")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{filepath.Join(root, "link", "new.txt"), filepath.Join(root, "link", "sub", "new.txt"), filepath.Join(root, "dangling")} {
+		if _, err = uploader.Exists(ctx, p); !errors.Is(err, PathEscapeErr) {
+			t.Errorf("expected exists of %s to be rejected, got %v", p, err)
+		}
+		if err = uploader.MoveObject(ctx, res.Path, p); !errors.Is(err, PathEscapeErr) {
+			t.Errorf("expected move to %s to be rejected, got %v", p, err)
+		}
+	}
+	if entries, _ := os.ReadDir(outsideDir); len(entries) != 0 || exists(filepath.Join(base, "missing.txt")) {
+		t.Fatal("file written outside root")
+	}
+}
+
+func TestLocalDeleteObjectsResult(t *testing.T) {
+	root := t.TempDir()
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	ctx := context.Background()
+
+	res, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(root, "missing.txt")
+	escaped := filepath.Join(root, "..", "x.txt")
+
+	result, err := uploader.DeleteObjects(ctx, []string{res.Path, missing, escaped})
+	if err == nil {
+		t.Fatal("expected error for escaped path")
+	}
+	if len(result.Deleted) != 2 || result.Deleted[0] != res.Path || result.Deleted[1] != missing {
+		t.Fatalf("unexpected deleted %v", result.Deleted)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Path != escaped || !errors.Is(result.Failed[0], PathEscapeErr) {
+		t.Fatalf("unexpected failed %v", result.Failed)
+	}
+}
+
+func TestLocalDeleteDirectory(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir(), Versioning: true})
+	ctx := context.Background()
+
+	res, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := uploader.metaPath(res.Path)
+
+	if _, err = uploader.DeleteObjects(ctx, []string{filepath.Dir(res.Path)}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Dir(res.Path)) || exists(meta) {
+		t.Fatal("directory or meta was not removed")
+	}
+
+	versions, err := uploader.ListVersions(ctx, res.Path)
+	if err != nil || len(versions) != 1 || versions[0].VersionID != res.VersionID {
+		t.Fatalf("unexpected versions %+v, %v", versions, err)
+	}
+}
+
+func TestLocalServerSideEncryption(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+
+	_, err := uploader.Upload(context.Background(), newSource(t, "a.txt", []byte("hello")), false,
+		WithServerSideEncryption(ServerSideEncryption{Algorithm: SSES3}))
+	if !errors.Is(err, UnsupportedOptionErr) {
+		t.Fatalf("expected UnsupportedOptionErr, got %v", err)
+	}
+}
+
+func TestLocalUploadProgress(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	content := bytes.Repeat([]byte("a"), 100*1024)
+
+	var events []ProgressEvent
+	_, err := uploader.Upload(context.Background(), newSource(t, "a.txt", content), false,
+		WithProgress(func(event ProgressEvent) {
+			events = append(events, event)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := events[len(events)-1]
+	if last.Type != ProgressCompleted || last.Transferred != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Fatalf("unexpected last event %+v", last)
+	}
+
+	for i := 1; i < len(events); i++ {
+		if events[i].Transferred < events[i-1].Transferred {
+			t.Fatalf("progress went backwards: %+v", events)
+		}
+	}
+}
+
+func TestLocalDeletePrefix(t *testing.T) {
+	root := t.TempDir()
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root})
+	ctx := context.Background()
+
+	for _, name := range []string{"dir/a.txt", "dir/sub/b.txt", "dir2/c.txt"} {
+		p := filepath.Join(root, name)
+		_ = os.MkdirAll(filepath.Dir(p), 0777)
+		_ = os.WriteFile(p, []byte(name), 0666)
+	}
+	prefix := filepath.Join(root, "dir") + "/"
+
+	for _, p := range []string{"", "/"} {
+		if _, err := uploader.DeletePrefix(ctx, p, false); !errors.Is(err, EmptyPrefixErr) {
+			t.Fatalf("expected EmptyPrefixErr for %q, got %v", p, err)
+		}
+	}
+	if _, err := uploader.DeletePrefix(ctx, root+"/", false); !errors.Is(err, PathEscapeErr) {
+		t.Fatalf("expected root prefix to be rejected, got %v", err)
+	}
+
+	res, err := uploader.DeletePrefix(ctx, prefix, true)
+	if err != nil || len(res.Deleted) != 2 {
+		t.Fatalf("unexpected dry run result %v, %v", res.Deleted, err)
+	}
+	if !exists(filepath.Join(root, "dir", "a.txt")) {
+		t.Fatal("dry run deleted files")
+	}
+
+	res, err = uploader.DeletePrefix(ctx, prefix, false)
+	if err != nil || len(res.Deleted) != 2 {
+		t.Fatalf("unexpected result %v, %v", res.Deleted, err)
+	}
+	if exists(filepath.Join(root, "dir")) || !exists(filepath.Join(root, "dir2", "c.txt")) {
+		t.Fatal("prefix deletion removed the wrong files")
+	}
+}
+
+func TestLocalVersioning(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir(), Versioning: true})
+	ctx := context.Background()
+
+	first, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("v1")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("v2")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.VersionID == "" || first.VersionID == second.VersionID {
+		t.Fatalf("unexpected version ids %q %q", first.VersionID, second.VersionID)
+	}
+
+	versions, err := uploader.ListVersions(ctx, second.Path)
+	if err != nil || len(versions) != 2 || !versions[0].IsLatest || versions[0].VersionID != second.VersionID {
+		t.Fatalf("unexpected versions %+v, %v", versions, err)
+	}
+
+	rc, err := uploader.GetObject(ctx, second.Path, WithVersionID(first.VersionID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(content) != "v1" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	restored, err := uploader.RestoreVersion(ctx, second.Path, first.VersionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ = os.ReadFile(second.Path); string(content) != "v1" {
+		t.Fatalf("unexpected restored content %q", content)
+	}
+	if info, _ := uploader.StatObject(ctx, second.Path); info.VersionID != restored {
+		t.Fatalf("expected version %s, got %s", restored, info.VersionID)
+	}
+
+	if err = uploader.DeleteVersion(ctx, second.Path, second.VersionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = uploader.DeleteObjects(ctx, []string{second.Path}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, _ = uploader.ListVersions(ctx, second.Path)
+	if exists(second.Path) || len(versions) != 2 || versions[0].IsLatest {
+		t.Fatalf("unexpected versions after delete %+v", versions)
+	}
+}
+
+func TestLocalDeleteCurrentVersion(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir(), Versioning: true})
+	ctx := context.Background()
+
+	first, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("v1")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("v2")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除当前版本后上一个版本成为当前版本
+	if err = uploader.DeleteVersion(ctx, second.Path, second.VersionID); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(second.Path); string(content) != "v1" {
+		t.Fatalf("unexpected current content %q", content)
+	}
+	versions, err := uploader.ListVersions(ctx, second.Path)
+	if err != nil || len(versions) != 1 || !versions[0].IsLatest || versions[0].VersionID != first.VersionID {
+		t.Fatalf("unexpected versions %+v, %v", versions, err)
+	}
+
+	// 没有历史版本时删除后对象不存在
+	if err = uploader.DeleteVersion(ctx, second.Path, first.VersionID); err != nil {
+		t.Fatal(err)
+	}
+	if exists(second.Path) {
+		t.Fatal("object still exists")
+	}
+}
+
+func TestLocalTagging(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	ctx := context.Background()
+
+	if caps := uploader.Capabilities(); !caps.Tagging || caps.Versioning {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+
+	res, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("hello")), false,
+		WithMetadata(map[string]string{"tenant": "42"}),
+		WithTags(map[string]string{"env": "dev"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := uploader.GetObjectTags(ctx, res.Path)
+	if err != nil || len(tags) != 1 || tags["env"] != "dev" {
+		t.Fatalf("unexpected tags %v, %v", tags, err)
+	}
+
+	if err = uploader.SetObjectTags(ctx, res.Path, map[string]string{"team": "storage"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ = uploader.GetObjectTags(ctx, res.Path); len(tags) != 1 || tags["team"] != "storage" {
+		t.Fatalf("expected tags to be replaced, got %v", tags)
+	}
+	if err = uploader.SetObjectTags(ctx, res.Path, map[string]string{"": "x"}); !errors.Is(err, InvalidTagErr) {
+		t.Fatalf("expected InvalidTagErr, got %v", err)
+	}
+
+	if err = uploader.DeleteObjectTags(ctx, res.Path); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ = uploader.GetObjectTags(ctx, res.Path); len(tags) != 0 {
+		t.Fatalf("expected no tags, got %v", tags)
+	}
+	if info, _ := uploader.StatObject(ctx, res.Path); info.Metadata["tenant"] != "42" {
+		t.Fatalf("tagging changed metadata %+v", info.Metadata)
+	}
+
+	if _, err = uploader.GetObjectTags(ctx, res.Path+".missing"); !errors.Is(err, ObjectNotFoundErr) {
+		t.Fatalf("expected ObjectNotFoundErr, got %v", err)
+	}
+}
+
+func TestLocalACL(t *testing.T) {
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir(), Domain: "https://cdn.example.com"})
+	ctx := context.Background()
+
+	res, err := uploader.Upload(ctx, newSource(t, "a.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res.FileUrl, "signature=") {
+		t.Fatalf("public object should not be signed: %s", res.FileUrl)
+	}
+
+	if _, err = uploader.Upload(ctx, newSource(t, "b.txt", []byte("hello")), false, WithACL(ACLPrivate)); !errors.Is(err, UnsupportedOptionErr) {
+		t.Fatalf("expected UnsupportedOptionErr without sign key, got %v", err)
+	}
+
+	uploader, _ = NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir(), ACL: ACLPrivate, SignKey: "secret"})
+	res, err = uploader.Upload(ctx, newSource(t, "a.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(res.FileUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if u.Query().Get("signature") != localSignature("secret", strings.TrimPrefix(u.Path, "/"), expires) {
+		t.Fatalf("unexpected signed url %s", res.FileUrl)
+	}
+
+	if err = uploader.SetACL(ctx, res.Path, ACLPublicRead); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := uploader.readMeta(res.Path); meta.ACL != ACLPublicRead || meta.MD5 == "" {
+		t.Fatalf("unexpected meta %+v", meta)
+	}
+	if err = uploader.SetACL(ctx, res.Path, ACLDefault); !errors.Is(err, UnsupportedOptionErr) {
+		t.Fatalf("expected UnsupportedOptionErr, got %v", err)
+	}
+}
+
+func TestLocalFileUrl(t *testing.T) {
+	root := t.TempDir()
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root, Domain: "cdn.example.com/", PublicPath: "/static/"})
+
+	res, err := uploader.Upload(context.Background(), newSource(t, "年报 2024.txt", []byte("hello")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel, _ := filepath.Rel(root, res.Path)
+	expect := "https://cdn.example.com/static/" + filepath.ToSlash(filepath.Dir(rel)) + "/%E5%B9%B4%E6%8A%A5%202024.txt"
+	if res.FileUrl != expect {
+		t.Fatalf("expected %s, got %s", expect, res.FileUrl)
+	}
+
+	uploader, _ = NewUploaderLocal(UploaderLocalConfig{LocalPath: root, Domain: "cdn.example.com", DisableSSL: true})
+	if url, _ := uploader.fileURL(res.Path, ACLDefault); !strings.HasPrefix(url, "http://cdn.example.com/") {
+		t.Fatalf("expected http url, got %s", url)
+	}
+}
+
+func TestLocalRange(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	compressed := NewCompressedUploader(local, Gzip)
+
+	content := []byte(strings.Repeat("0123456789", 100))
+	plain, err := local.Upload(ctx, newSource(t, "a.txt", content), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipped, err := compressed.Upload(ctx, newSource(t, "b.txt", content), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		offset, length int64
+		expect         string
+	}{
+		{0, 3, "012"},
+		{995, 0, "56789"},
+		{998, 10, "89"},
+		{12, 1, "2"},
+	} {
+		for _, read := range []func() (io.ReadCloser, error){
+			func() (io.ReadCloser, error) {
+				return local.GetObject(ctx, plain.Path, WithRange(tc.offset, tc.length))
+			},
+			func() (io.ReadCloser, error) {
+				return compressed.GetObject(ctx, gzipped.Path, WithRange(tc.offset, tc.length))
+			},
+		} {
+			rc, err := read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			if string(got) != tc.expect {
+				t.Fatalf("range %d+%d: expected %q, got %q", tc.offset, tc.length, tc.expect, got)
+			}
+		}
+	}
+
+	if _, err = local.GetObject(ctx, plain.Path, WithRange(1000, 1)); !errors.Is(err, InvalidRangeErr) {
+		t.Fatalf("expected InvalidRangeErr, got %v", err)
+	}
+	if _, err = compressed.GetObject(ctx, gzipped.Path, WithRange(1000, 1)); !errors.Is(err, InvalidRangeErr) {
+		t.Fatalf("expected InvalidRangeErr for compressed object, got %v", err)
+	}
+}
+
+func TestLocalReservedPath(t *testing.T) {
+	root := t.TempDir()
+	uploader, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: root, ACL: ACLPrivate, SignKey: "secret"})
+	ctx := context.Background()
+
+	res, err := uploader.Upload(ctx, newSource(t, "secret.txt", []byte("secret")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 与元数据同名的上传是普通对象，不会影响原对象的访问权限
+	if _, err = uploader.Upload(ctx, newSource(t, "secret.txt.meta.json", []byte(`{"acl":"public-read"}`)), false); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := uploader.readMeta(res.Path); meta.ACL != ACLPrivate {
+		t.Fatalf("unexpected acl %q", meta.ACL)
+	}
+
+	metaPath, _ := uploader.metaPath(res.Path)
+	for _, p := range []string{metaPath, filepath.Join(root, localVersionsDir, "a.txt")} {
+		if err = uploader.MoveObject(ctx, res.Path, p); !errors.Is(err, ReservedPathErr) {
+			t.Fatalf("expected ReservedPathErr for %s, got %v", p, err)
+		}
+	}
+
+	var keys []string
+	_ = uploader.ListObjects(ctx, filepath.Join(root, ".m"), func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if len(keys) != 0 {
+		t.Fatalf("reserved files listed: %v", keys)
+	}
+}
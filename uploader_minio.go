@@ -2,12 +2,20 @@ package file_storage
 
 import (
 	"context"
+	"encoding/xml"
 	"errors"
+	"fmt"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"github.com/minio/minio-go/v7/pkg/tags"
 	"github.com/qiuyier/file-storage/pkg/util"
-	"mime/multipart"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 type UploaderMinioConfig struct {
@@ -18,13 +26,27 @@ type UploaderMinioConfig struct {
 	Path            string
 	UseSSL          bool
 	Domain          string
+	// Overwrite 同名文件处理策略，minio 不支持服务端禁止覆盖，非覆盖策略为先检查再上传，并发上传同名文件时仍可能覆盖
+	Overwrite OverwritePolicy
+	// SSE 服务端加密，SSE-C 要求 UseSSL
+	SSE *ServerSideEncryption
+	// ACL 默认访问权限，为空时继承存储桶设置
+	ACL ACL
+	// URLExpires 私有对象签名 URL 的有效期，默认 1 小时
+	URLExpires time.Duration
+	// URLStyle 访问地址风格，默认路径风格，此时 Domain 之后仍会拼接存储桶名
+	URLStyle URLStyle
 }
 
 type UploaderMinio struct {
 	client     *minio.Client
 	bucketName string
 	path       string
-	domain     string
+	url        urlBuilder
+	overwrite  OverwritePolicy
+	sse        *ServerSideEncryption
+	acl        ACL
+	urlExpires time.Duration
 }
 
 func NewUploaderMinio(config UploaderMinioConfig) (uploader *UploaderMinio, err error) {
@@ -40,33 +62,109 @@ func NewUploaderMinio(config UploaderMinioConfig) (uploader *UploaderMinio, err
 		client:     client,
 		bucketName: config.BucketName,
 		path:       config.Path,
-		domain:     config.Domain,
+		url:        minioURLBuilder(config),
+		overwrite:  config.Overwrite,
+		sse:        config.SSE,
+		acl:        config.ACL,
+		urlExpires: urlExpires(config.URLExpires),
 	}
 	return
 }
 
-func (u *UploaderMinio) Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error) {
+func (u *UploaderMinio) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	o := newUploadOptions(opts...)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+
+	serverSide, err := minioSSE(sse)
+	if err != nil {
+		return res, err
+	}
+
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
 	if err = s3utils.CheckValidBucketName(u.bucketName); err != nil {
-		return "", "", err
+		return res, err
 	}
 
-	path = util.GenName(u.path, file.Filename, randomly)
+	path := util.GenName(u.path, file.Name(), randomly)
 
 	if err = s3utils.CheckValidObjectName(path); err != nil {
-		return "", "", err
+		return res, err
+	}
+
+	path, err = resolvePath(ctx, u.Exists, path, o.overwritePolicy(u.overwrite))
+	if err != nil {
+		return res, err
 	}
 
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
+	options := minio.PutObjectOptions{
+		ContentType:          o.ContentType,
+		CacheControl:         o.CacheControl,
+		ContentDisposition:   o.ContentDisposition,
+		ContentEncoding:      o.ContentEncoding,
+		UserMetadata:         minioMetadata(o.Metadata, acl),
+		UserTags:             o.Tags,
+		SendContentMd5:       true,
+		ServerSideEncryption: serverSide,
+	}
+
+	progress := newProgressTracker(o.Progress, file.Size())
+	if progress != nil {
+		options.Progress = progress
+	}
+
+	// 长度未知时 SDK 按 PartSize 缓存分片上传
+	if file.Size() < 0 {
+		options.PartSize = streamPartSize * 1024 * 1024
+	}
+
+	// 上传的同时计算校验值，不暴露 ReaderAt，SDK 按顺序读取
+	body := newChecksumReader(throttle(ctx, fd, o.RateLimiters))
+	info, err := u.client.PutObject(ctx, u.bucketName, path, body, file.Size(), options)
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, err
+	}
+	checksum := body.Sum()
+
+	// 长度未知时为分片上传，ETag 不是内容 MD5
+	if file.Size() >= 0 && sse.etagIsMD5() {
+		if err = verifyETag(info.ETag, checksum.MD5); err != nil {
+			return res, err
+		}
 	}
 
-	contentType := util.GetContentType(util.Ext(file.Filename))
-	fileUrl = util.Join(u.domain, u.bucketName, path)
+	progress.completed()
 
-	_, err = u.client.PutObject(ctx, u.bucketName, path, fd, file.Size, minio.PutObjectOptions{ContentType: contentType})
+	fileUrl, err := u.fileURL(ctx, path, acl)
+	if err != nil {
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: info.VersionID,
+	}
 
 	return
 }
@@ -75,19 +173,426 @@ func (u *UploaderMinio) GetUploaderType() string {
 	return Minio
 }
 
-func (u *UploaderMinio) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error) {
-	return "", "", errors.New("minio driver does not support multipart upload")
+func (u *UploaderMinio) Exists(ctx context.Context, path string) (bool, error) {
+	_, err := u.client.StatObject(ctx, u.bucketName, path, minio.StatObjectOptions{})
+	if err != nil {
+		if errors.Is(minioNotFoundErr(err), ObjectNotFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (u *UploaderMinio) DeleteObjects(ctx context.Context, path []string) error {
-	var err error
+func (u *UploaderMinio) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	o := newObjectOptions(opts...)
+	rangeHeader, err := o.rangeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	options := minio.GetObjectOptions{VersionID: o.VersionID}
+	if u.sse.isSSEC() {
+		options.ServerSideEncryption, _ = minioSSE(u.sse)
+	}
+	if rangeHeader != "" {
+		options.Set("Range", rangeHeader)
+	}
+
+	object, err := u.client.GetObject(ctx, u.bucketName, path, options)
+	if err != nil {
+		return nil, minioNotFoundErr(err)
+	}
+
+	// GetObject 在首次读取时才发起请求，先 Stat 以便尽早返回对象不存在等错误
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		return nil, minioNotFoundErr(err)
+	}
+
+	return object, nil
+}
+
+func (u *UploaderMinio) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	options := minio.StatObjectOptions{VersionID: newObjectOptions(opts...).VersionID}
+	if u.sse.isSSEC() {
+		options.ServerSideEncryption, _ = minioSSE(u.sse)
+	}
+
+	stat, err := u.client.StatObject(ctx, u.bucketName, path, options)
+	if err != nil {
+		return ObjectInfo{}, minioNotFoundErr(err)
+	}
+
+	return ObjectInfo{
+		Path:            path,
+		Size:            stat.Size,
+		ETag:            stat.ETag,
+		ContentType:     stat.ContentType,
+		ContentEncoding: stat.Metadata.Get("Content-Encoding"),
+		LastModified:    stat.LastModified,
+		VersionID:       stat.VersionID,
+		Metadata:        lowerMetadata(stat.UserMetadata, ""),
+	}, nil
+}
+
+// minioSSE 将服务端加密配置转换为 minio 的加密选项
+func minioSSE(sse *ServerSideEncryption) (encrypt.ServerSide, error) {
+	if sse == nil {
+		return nil, nil
+	}
+
+	switch sse.Algorithm {
+	case SSEKMS:
+		return encrypt.NewSSEKMS(sse.KMSKeyID, nil)
+	case SSEC:
+		return encrypt.NewSSEC(sse.CustomerKey)
+	default:
+		return encrypt.NewSSE(), nil
+	}
+}
+
+// minioURLBuilder 未配置 Domain 时使用 EndPoint
+func minioURLBuilder(config UploaderMinioConfig) urlBuilder {
+	domain := config.Domain
+	if config.URLStyle == URLStyleVirtualHosted {
+		if domain == "" {
+			domain = config.BucketName + "." + config.EndPoint
+		}
+		return newURLBuilder(domain, config.UseSSL)
+	}
+
+	if domain == "" {
+		domain = config.EndPoint
+	}
+
+	return newURLBuilder(domain, config.UseSSL, config.BucketName)
+}
+
+// minioMetadata x-amz- 开头的 key 由 SDK 原样作为请求头发送
+func minioMetadata(metadata map[string]string, acl ACL) map[string]string {
+	if acl == ACLDefault {
+		return metadata
+	}
+
+	meta := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta["x-amz-acl"] = string(acl)
+
+	return meta
+}
+
+// fileURL 私有对象返回带有效期的签名 URL
+func (u *UploaderMinio) fileURL(ctx context.Context, path string, acl ACL) (string, error) {
+	if acl != ACLPrivate {
+		return u.url.build(path), nil
+	}
+
+	signed, err := u.client.PresignedGetObject(ctx, u.bucketName, path, u.urlExpires, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return signed.String(), nil
+}
+
+func minioNotFoundErr(err error) error {
+	switch minio.ToErrorResponse(err).StatusCode {
+	case http.StatusNotFound:
+		return ObjectNotFoundErr
+	case http.StatusRequestedRangeNotSatisfiable:
+		return InvalidRangeErr
+	}
+
+	return err
+}
+
+func (u *UploaderMinio) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	return res, errors.New("minio driver does not support multipart upload")
+}
+
+func (u *UploaderMinio) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for _, v := range path {
+			objectsCh <- minio.ObjectInfo{Key: v}
+		}
+	}()
+
+	// SDK 内部按每批 1000 个对象发送请求
+	failed := make(map[string]struct{})
+	for v := range u.client.RemoveObjects(ctx, u.bucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+		failed[v.ObjectName] = struct{}{}
+		code := minio.ToErrorResponse(v.Err).Code
+		res.fail(v.ObjectName, code, deleteCodeErr(code, v.Err.Error()))
+	}
 
 	for _, v := range path {
-		err = u.client.RemoveObject(ctx, u.bucketName, v, minio.RemoveObjectOptions{})
-		if err != nil {
+		if _, ok := failed[v]; !ok {
+			res.Deleted = append(res.Deleted, v)
+		}
+	}
+
+	return res, res.Err()
+}
+
+func (u *UploaderMinio) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+func (u *UploaderMinio) ListObjects(ctx context.Context, prefix string, fn func(key string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for v := range u.client.ListObjects(ctx, u.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if v.Err != nil {
+			return v.Err
+		}
+
+		if err := fn(v.Key); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// MoveObject minio 复制不支持禁止覆盖，WithNoOverwrite 时先检查目标再复制，并发时仍可能覆盖
+func (u *UploaderMinio) MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error {
+	acl, err := u.getACL(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	if newObjectOptions(opts...).NoOverwrite {
+		exists, err := u.Exists(ctx, dst)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ObjectExistsErr
+		}
+	}
+
+	if _, err = u.copyObject(ctx, src, "", dst); err != nil {
+		return err
+	}
+
+	// 复制后的对象为私有权限，源对象为公共读时重新设置；设置失败时删除已复制的对象，避免留下副本
+	if acl != ACLPrivate {
+		if err = u.SetACL(ctx, dst, acl); err != nil {
+			_ = u.client.RemoveObject(ctx, u.bucketName, dst, minio.RemoveObjectOptions{})
+			return err
+		}
+	}
+
+	return u.client.RemoveObject(ctx, u.bucketName, src, minio.RemoveObjectOptions{})
+}
+
+// minioMaxCopySize 单次复制的对象上限
+const minioMaxCopySize = 5 << 30
+
+// copyObject 服务端复制，srcVersionID 为空时复制最新版本
+// 超过单次复制上限时由 ComposeObject 分片复制，分片复制不会保留源对象的属性及标签，需重新设置
+func (u *UploaderMinio) copyObject(ctx context.Context, src, srcVersionID, dst string) (minio.UploadInfo, error) {
+	srcOptions := minio.CopySrcOptions{Bucket: u.bucketName, Object: src, VersionID: srcVersionID}
+	dstOptions := minio.CopyDestOptions{Bucket: u.bucketName, Object: dst}
+
+	if u.sse != nil {
+		serverSide, err := minioSSE(u.sse)
+		if err != nil {
+			return minio.UploadInfo{}, err
+		}
+		dstOptions.Encryption = serverSide
+		// SSE-C 对象复制时需同时提供源对象的密钥
+		if u.sse.isSSEC() {
+			srcOptions.Encryption = serverSide
+		}
+	}
+
+	stat, err := u.client.StatObject(ctx, u.bucketName, src, minio.StatObjectOptions{VersionID: srcVersionID, ServerSideEncryption: srcOptions.Encryption})
+	if err != nil {
+		return minio.UploadInfo{}, minioNotFoundErr(err)
+	}
+
+	if stat.Size <= minioMaxCopySize {
+		info, err := u.client.CopyObject(ctx, dstOptions, srcOptions)
+		if err != nil {
+			return info, minioNotFoundErr(err)
+		}
+
+		return info, nil
+	}
+
+	t, err := u.client.GetObjectTagging(ctx, u.bucketName, src, minio.GetObjectTaggingOptions{VersionID: srcVersionID})
+	if err != nil {
+		return minio.UploadInfo{}, minioNotFoundErr(err)
+	}
+
+	dstOptions.ReplaceMetadata = true
+	dstOptions.UserMetadata = make(map[string]string)
+	for k, v := range copyHeaders(stat.Metadata, "x-amz-meta-") {
+		dstOptions.UserMetadata[k] = v[0]
+	}
+	dstOptions.ReplaceTags = true
+	dstOptions.UserTags = t.ToMap()
+
+	info, err := u.client.ComposeObject(ctx, dstOptions, srcOptions)
+	if err != nil {
+		return info, minioNotFoundErr(err)
+	}
+
+	return info, nil
+}
+
+func (u *UploaderMinio) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var versions []ObjectVersion
+	for v := range u.client.ListObjects(ctx, u.bucketName, minio.ListObjectsOptions{Prefix: path, Recursive: true, WithVersions: true}) {
+		if v.Err != nil {
+			return nil, v.Err
+		}
+
+		// 前缀匹配会包含其他以 path 开头的对象
+		if v.Key != path {
+			continue
+		}
+
+		versions = append(versions, ObjectVersion{
+			Path:           v.Key,
+			VersionID:      v.VersionID,
+			Size:           v.Size,
+			ETag:           v.ETag,
+			IsLatest:       v.IsLatest,
+			IsDeleteMarker: v.IsDeleteMarker,
+			LastModified:   v.LastModified,
+		})
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (u *UploaderMinio) DeleteVersion(ctx context.Context, path, versionID string) error {
+	return u.client.RemoveObject(ctx, u.bucketName, path, minio.RemoveObjectOptions{VersionID: versionID})
+}
+
+func (u *UploaderMinio) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	info, err := u.copyObject(ctx, path, versionID, path)
+	if err != nil {
+		return "", err
+	}
+
+	return info.VersionID, nil
+}
+
+func (u *UploaderMinio) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Tagging: true, ACL: true}
+}
+
+func (u *UploaderMinio) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	t, err := u.client.GetObjectTagging(ctx, u.bucketName, path, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, minioNotFoundErr(err)
+	}
+
+	return t.ToMap(), nil
+}
+
+func (u *UploaderMinio) SetObjectTags(ctx context.Context, path string, tagMap map[string]string) error {
+	if err := validateTags(tagMap); err != nil {
+		return err
+	}
+
+	t, err := tags.NewTags(tagMap, true)
+	if err != nil {
+		return fmt.Errorf("%w: %s", InvalidTagErr, err.Error())
+	}
+
+	return minioNotFoundErr(u.client.PutObjectTagging(ctx, u.bucketName, path, t, minio.PutObjectTaggingOptions{}))
+}
+
+func (u *UploaderMinio) DeleteObjectTags(ctx context.Context, path string) error {
+	return minioNotFoundErr(u.client.RemoveObjectTagging(ctx, u.bucketName, path, minio.RemoveObjectTaggingOptions{}))
+}
+
+// SetACL SDK 未提供修改对象权限的接口，通过 aclRequest 直接请求
+func (u *UploaderMinio) SetACL(ctx context.Context, path string, acl ACL) error {
+	if err := acl.validate(); err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("x-amz-acl", string(acl))
+
+	_, err := u.aclRequest(ctx, http.MethodPut, path, header)
+
+	return err
+}
+
+// getACL 读取对象的访问权限，AllUsers 可读时为公共读，否则为私有
+func (u *UploaderMinio) getACL(ctx context.Context, path string) (ACL, error) {
+	body, err := u.aclRequest(ctx, http.MethodGet, path, http.Header{})
+	if err != nil {
+		return ACLDefault, err
+	}
+
+	var policy struct {
+		Grants []struct {
+			URI        string `xml:"Grantee>URI"`
+			Permission string `xml:"Permission"`
+		} `xml:"AccessControlList>Grant"`
+	}
+	if err = xml.Unmarshal(body, &policy); err != nil {
+		return ACLDefault, errors.New("decode acl " + path + ", err: " + err.Error())
+	}
+
+	for _, v := range policy.Grants {
+		if v.URI == "http://acs.amazonaws.com/groups/global/AllUsers" && v.Permission == "READ" {
+			return ACLPublicRead, nil
+		}
+	}
+
+	return ACLPrivate, nil
+}
+
+// aclRequest 通过签名 URL 直接请求 acl 子资源
+func (u *UploaderMinio) aclRequest(ctx context.Context, method, path string, header http.Header) ([]byte, error) {
+	signed, err := u.client.PresignHeader(ctx, method, u.bucketName, path, u.urlExpires, url.Values{"acl": {""}}, header)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, signed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ObjectNotFoundErr
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%s acl %s, status: %d, body: %s", strings.ToLower(method), path, resp.StatusCode, data)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,47 @@
+package file_storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMinioListVersionsSorted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if _, ok := r.URL.Query()["location"]; ok {
+			_, _ = w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+			return
+		}
+
+		// 版本与删除标记分开返回，顺序与时间无关
+		_, _ = w.Write([]byte(`<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+<Name>bucket</Name><Prefix>a.txt</Prefix><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>
+<Version><Key>a.txt</Key><VersionId>v1</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:00.000Z</LastModified><Size>1</Size></Version>
+<Version><Key>a.txt</Key><VersionId>v3</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-03T00:00:00.000Z</LastModified><Size>1</Size></Version>
+<DeleteMarker><Key>a.txt</Key><VersionId>v4</VersionId><IsLatest>true</IsLatest><LastModified>2024-01-04T00:00:00.000Z</LastModified></DeleteMarker>
+<DeleteMarker><Key>a.txt</Key><VersionId>v2</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-02T00:00:00.000Z</LastModified></DeleteMarker>
+</ListVersionsResult>`))
+	}))
+	defer server.Close()
+
+	uploader, err := NewUploaderMinio(UploaderMinioConfig{EndPoint: strings.TrimPrefix(server.URL, "http://"), BucketName: "bucket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := uploader.ListVersions(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, v := range versions {
+		ids = append(ids, v.VersionID)
+	}
+	if strings.Join(ids, ",") != "v4,v3,v2,v1" || !versions[0].IsLatest {
+		t.Fatalf("unexpected versions order %v", ids)
+	}
+}
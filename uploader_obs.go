@@ -1,11 +1,19 @@
 package file_storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
 	"errors"
+	"fmt"
 	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
 	"github.com/qiuyier/file-storage/pkg/util"
-	"mime/multipart"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 )
 
 type UploaderObsConfig struct {
@@ -15,13 +23,27 @@ type UploaderObsConfig struct {
 	BucketName      string
 	Path            string
 	Domain          string
+	// Overwrite 同名文件处理策略，obs 不支持服务端禁止覆盖，非覆盖策略为先检查再上传，并发上传同名文件时仍可能覆盖
+	Overwrite OverwritePolicy
+	// SSE 服务端加密
+	SSE *ServerSideEncryption
+	// ACL 默认访问权限，为空时继承存储桶设置
+	ACL ACL
+	// URLExpires 私有对象签名 URL 的有效期，默认 1 小时
+	URLExpires time.Duration
+	// DisableSSL Domain 及 EndPoint 未带协议头时使用 http，默认 https
+	DisableSSL bool
 }
 
 type UploaderObs struct {
-	client *obs.ObsClient
-	path   string
-	domain string
-	bucket string
+	client     *obs.ObsClient
+	path       string
+	url        urlBuilder
+	bucket     string
+	overwrite  OverwritePolicy
+	sse        *ServerSideEncryption
+	acl        ACL
+	urlExpires time.Duration
 }
 
 func NewUploaderObs(config UploaderObsConfig) (uploader *UploaderObs, err error) {
@@ -31,34 +53,106 @@ func NewUploaderObs(config UploaderObsConfig) (uploader *UploaderObs, err error)
 	}
 
 	uploader = &UploaderObs{
-		client: obsClient,
-		path:   config.Path,
-		domain: config.Domain,
-		bucket: config.BucketName,
+		client:     obsClient,
+		path:       config.Path,
+		url:        newURLBuilder(obsDomain(config), !config.DisableSSL),
+		bucket:     config.BucketName,
+		overwrite:  config.Overwrite,
+		sse:        config.SSE,
+		acl:        config.ACL,
+		urlExpires: urlExpires(config.URLExpires),
 	}
 
 	return
 }
 
-func (u *UploaderObs) Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error) {
-	path = util.GenName(u.path, file.Filename, randomly)
+func (u *UploaderObs) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	// 长度未知时无法计算整体 Content-MD5，改为逐个分片读入内存后分片上传
+	if file.Size() < 0 {
+		return u.MultipartUpload(ctx, file, randomly, streamPartSize, opts...)
+	}
+
+	o := newUploadOptions(opts...)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
+	path := util.GenName(u.path, file.Name(), randomly)
+
+	path, err = resolvePath(ctx, u.Exists, path, o.overwritePolicy(u.overwrite))
+	if err != nil {
+		return res, err
+	}
 
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
 	input := &obs.PutObjectInput{}
 
 	input.Bucket = u.bucket
 
 	input.Key = path
 
-	input.Body = fd
+	// 上传的同时计算校验值，上传后与服务端返回的 ETag 比较
+	body := newChecksumReader(obsContextReader(ctx, throttle(ctx, fd, o.RateLimiters)))
+
+	input.Body = body
+
+	input.ContentLength = file.Size()
+
+	input.HttpHeader = obsHttpHeader(o)
+
+	input.Metadata = o.Metadata
+
+	input.SseHeader = obsSseHeader(sse)
 
-	_, err = u.client.PutObject(input)
+	input.ACL = obs.AclType(acl)
+
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	output, err := u.client.PutObject(input, obs.WithProgress(obsProgressListener(progress)), obsTaggingHeader(o.Tags))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return res, ctxErr
+		}
+		return res, errors.New("put object " + path + ", err: " + err.Error())
+	}
+	checksum := body.Sum()
+
+	if sse.etagIsMD5() {
+		if err = verifyETag(output.ETag, checksum.MD5); err != nil {
+			return res, err
+		}
+	}
+
+	progress.completed()
+
+	fileUrl, err := u.fileURL(path, acl)
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: output.VersionId,
 	}
-	fileUrl = util.Join(u.domain, path)
 
 	return
 }
@@ -67,64 +161,114 @@ func (u *UploaderObs) GetUploaderType() string {
 	return HuaWei
 }
 
-func (u *UploaderObs) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error) {
+func (u *UploaderObs) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	o := newUploadOptions(opts...)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
 	// 上传路径
-	path = util.GenName(u.path, file.Filename, randomly)
+	path := util.GenName(u.path, file.Name(), randomly)
 
-	inputInit := &obs.InitiateMultipartUploadInput{}
-	// 指定存储桶名称
-	inputInit.Bucket = u.bucket
-	// 指定对象名
-	inputInit.Key = path
-	// 初始化上传段任务
-	outputInit, err := u.client.InitiateMultipartUpload(inputInit)
+	path, err = resolvePath(ctx, u.Exists, path, o.overwritePolicy(u.overwrite))
 	if err != nil {
-		return "", "", errors.New("init multipart upload err: " + err.Error())
+		return res, err
 	}
 
 	// 获取文件信息
 	fd, err := file.Open()
-	defer fd.Close()
-
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
 	}
+	defer fd.Close()
 
-	uploadId := outputInit.UploadId
+	o.resolveContentType(fd, file.Name())
 
 	// 计算分块大小和分块数量
 	chunkSize = chunkSize * 1024 * 1024
-	chunks, err := util.SplitFileByPartSize(fd, file.Size, int64(chunkSize))
+	chunks, err := newChunkReader(fd, file.Size(), int64(chunkSize))
 	if err != nil {
-		return "", "", err
+		return res, err
 	}
 
+	inputInit := &obs.InitiateMultipartUploadInput{}
+	// 指定存储桶名称
+	inputInit.Bucket = u.bucket
+	// 指定对象名
+	inputInit.Key = path
+	// 指定对象属性
+	inputInit.HttpHeader = obsHttpHeader(o)
+	inputInit.Metadata = o.Metadata
+	inputInit.SseHeader = obsSseHeader(sse)
+	inputInit.ACL = obs.AclType(acl)
+	// 初始化上传段任务
+	outputInit, err := u.client.InitiateMultipartUpload(inputInit, obsTaggingHeader(o.Tags))
+	if err != nil {
+		return res, errors.New("init multipart upload err: " + err.Error())
+	}
+
+	uploadId := outputInit.UploadId
+
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	// 上传段，同时计算整体校验值
+	w := newChecksumWriter()
+	var partMD5s [][]byte
 	var opt []obs.Part
-	for _, chunk := range chunks {
+	for {
+		chunk, err := chunks.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			u.abortMultipartUpload(path, uploadId)
+			return res, err
+		}
+
+		partMD5, err := partChecksum(chunk.Buf, w)
+		if err != nil {
+			u.abortMultipartUpload(path, uploadId)
+			return res, err
+		}
+		partMD5s = append(partMD5s, partMD5)
+
 		inputUploadPart := &obs.UploadPartInput{}
 		inputUploadPart.Bucket = u.bucket
 		inputUploadPart.Key = path
 		inputUploadPart.UploadId = uploadId
 		inputUploadPart.PartNumber = chunk.Number
+		inputUploadPart.ContentMD5 = base64.StdEncoding.EncodeToString(partMD5)
+		// SSE-C 每个段都需要携带密钥
+		if sse.isSSEC() {
+			inputUploadPart.SseHeader = obsSseHeader(sse)
+		}
 
-		inputUploadPart.Body = chunk.Buf
-		outputUploadPart, err := u.client.UploadPart(inputUploadPart)
+		inputUploadPart.Body = obsContextReader(ctx, throttle(ctx, chunk.Buf, o.RateLimiters))
+		inputUploadPart.PartSize = chunk.Size
+		outputUploadPart, err := u.client.UploadPart(inputUploadPart, obs.WithProgress(obsProgressListener(progress)))
 		if err != nil {
-			abortInput := &obs.AbortMultipartUploadInput{}
-			// 指定存储桶名称
-			abortInput.Bucket = u.bucket
-			// 指定上传对象名
-			abortInput.Key = path
-			// 指定多段上传任务号
-			abortInput.UploadId = uploadId
-			// 取消分段上传任务
-			_, _ = u.client.AbortMultipartUpload(abortInput)
-			return "", "", err
+			u.abortMultipartUpload(path, uploadId)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return res, ctxErr
+			}
+			return res, err
 		}
 
 		PartETag := outputUploadPart.ETag
 		opt = append(opt, obs.Part{PartNumber: chunk.Number, ETag: PartETag})
+		progress.partCompleted(chunk.Number, 0)
 	}
+	checksum := w.Sum()
 
 	// 上传完成
 	inputCompleteMultipart := &obs.CompleteMultipartUploadInput{}
@@ -132,27 +276,634 @@ func (u *UploaderObs) MultipartUpload(ctx context.Context, file *multipart.FileH
 	inputCompleteMultipart.Key = path
 	inputCompleteMultipart.UploadId = uploadId
 	inputCompleteMultipart.Parts = opt
-	_, _ = u.client.CompleteMultipartUpload(inputCompleteMultipart)
+	outputComplete, err := u.client.CompleteMultipartUpload(inputCompleteMultipart)
+	if err != nil {
+		u.abortMultipartUpload(path, uploadId)
+		return res, errors.New("complete multipart upload err: " + err.Error())
+	}
+
+	if sse.etagIsMD5() {
+		if err = verifyETag(outputComplete.ETag, multipartETag(partMD5s)); err != nil {
+			return res, err
+		}
+	}
+
+	progress.completed()
 
-	fileUrl = util.Join(u.domain, path)
+	fileUrl, err := u.fileURL(path, acl)
+	if err != nil {
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: outputComplete.VersionId,
+	}
 
 	return
 }
 
-func (u *UploaderObs) DeleteObjects(ctx context.Context, path []string) error {
-	input := &obs.DeleteObjectsInput{}
+// abortMultipartUpload 取消分段上传任务
+func (u *UploaderObs) abortMultipartUpload(path, uploadId string) {
+	abortInput := &obs.AbortMultipartUploadInput{}
 	// 指定存储桶名称
+	abortInput.Bucket = u.bucket
+	// 指定上传对象名
+	abortInput.Key = path
+	// 指定多段上传任务号
+	abortInput.UploadId = uploadId
+	_, _ = u.client.AbortMultipartUpload(abortInput)
+}
+
+func (u *UploaderObs) Exists(ctx context.Context, path string) (bool, error) {
+	input := &obs.GetObjectMetadataInput{}
+	input.Bucket = u.bucket
+	input.Key = path
+
+	_, err := u.client.GetObjectMetadata(input)
+	if err != nil {
+		if errors.Is(obsNotFoundErr(err), ObjectNotFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (u *UploaderObs) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	o := newObjectOptions(opts...)
+	rangeHeader, err := o.rangeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &obs.GetObjectInput{}
+	input.Bucket = u.bucket
+	input.Key = path
+	input.VersionId = o.VersionID
+	if u.sse.isSSEC() {
+		input.SseHeader = obsSseHeader(u.sse)
+	}
+
+	output, err := u.client.GetObject(input, obsRangeHeader(rangeHeader))
+	if err != nil {
+		return nil, obsNotFoundErr(err)
+	}
+
+	return output.Body, nil
+}
+
+func (u *UploaderObs) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	input := &obs.GetObjectMetadataInput{}
+	input.Bucket = u.bucket
+	input.Key = path
+	input.VersionId = newObjectOptions(opts...).VersionID
+	if u.sse.isSSEC() {
+		input.SseHeader = obsSseHeader(u.sse)
+	}
+
+	output, err := u.client.GetObjectMetadata(input)
+	if err != nil {
+		return ObjectInfo{}, obsNotFoundErr(err)
+	}
+
+	return ObjectInfo{
+		Path:            path,
+		Size:            output.ContentLength,
+		ETag:            strings.Trim(output.ETag, `"`),
+		ContentType:     output.ContentType,
+		ContentEncoding: output.ContentEncoding,
+		LastModified:    output.LastModified,
+		VersionID:       output.VersionId,
+		Metadata:        lowerMetadata(output.Metadata, ""),
+	}, nil
+}
+
+// obsProgress 适配 obs 的进度回调
+type obsProgress consumedTracker
+
+func (p *obsProgress) ProgressChanged(event *obs.ProgressEvent) {
+	(*consumedTracker)(p).update(event.ConsumedBytes)
+}
+
+// obsProgressListener 未设置进度回调时返回 nil，SDK 不会回调
+func obsProgressListener(progress *progressTracker) obs.ProgressListener {
+	if progress == nil {
+		return nil
+	}
+
+	return &obsProgress{tracker: progress}
+}
+
+func obsNotFoundErr(err error) error {
+	var obsErr obs.ObsError
+	if errors.As(err, &obsErr) {
+		switch obsErr.StatusCode {
+		case http.StatusNotFound:
+			return ObjectNotFoundErr
+		case http.StatusRequestedRangeNotSatisfiable:
+			return InvalidRangeErr
+		}
+	}
+
+	return err
+}
+
+// obsSseHeader 将服务端加密配置转换为 obs 加密头
+func obsSseHeader(sse *ServerSideEncryption) obs.ISseHeader {
+	if sse == nil {
+		return nil
+	}
+
+	switch sse.Algorithm {
+	case SSEKMS:
+		return obs.SseKmsHeader{Key: sse.KMSKeyID}
+	case SSEC:
+		return obs.SseCHeader{Key: sse.customerKey(), KeyMD5: sse.customerKeyMD5()}
+	default:
+		return obs.SseKmsHeader{Encryption: "AES256"}
+	}
+}
+
+// obsHttpHeader 将上传选项转换为 obs 请求头
+func obsHttpHeader(o *UploadOptions) obs.HttpHeader {
+	return obs.HttpHeader{
+		ContentType:        o.ContentType,
+		CacheControl:       o.CacheControl,
+		ContentDisposition: o.ContentDisposition,
+		ContentEncoding:    o.ContentEncoding,
+	}
+}
+
+// obsDomain 未配置 Domain 时使用存储桶的访问域名
+func obsDomain(config UploaderObsConfig) string {
+	if config.Domain != "" {
+		return config.Domain
+	}
+
+	return virtualHostedDomain(config.BucketName, config.EndPoint)
+}
+
+// fileURL 私有对象返回带有效期的签名 URL
+func (u *UploaderObs) fileURL(path string, acl ACL) (string, error) {
+	if acl != ACLPrivate {
+		return u.url.build(path), nil
+	}
+
+	output, err := u.client.CreateSignedUrl(&obs.CreateSignedUrlInput{
+		Method:  obs.HttpMethodGet,
+		Bucket:  u.bucket,
+		Key:     path,
+		Expires: int(u.urlExpires / time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return output.SignedUrl, nil
+}
+
+func (u *UploaderObs) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
+	for _, chunk := range chunkPaths(path, maxDeleteBatch) {
+		input := &obs.DeleteObjectsInput{}
+		// 指定存储桶名称
+		input.Bucket = u.bucket
+		// 指定删除对象
+		objects := make([]obs.ObjectToDelete, len(chunk))
+		for k, v := range chunk {
+			objects[k] = obs.ObjectToDelete{Key: v, VersionId: ""}
+		}
+
+		input.Objects = objects
+		// 非静默模式返回每个对象的删除结果
+		input.Quiet = false
+		// 删除对象
+		output, err := u.client.DeleteObjects(input)
+		if err != nil {
+			res.failAll(chunk, err)
+			continue
+		}
+
+		for _, v := range output.Deleteds {
+			res.Deleted = append(res.Deleted, v.Key)
+		}
+		for _, v := range output.Errors {
+			res.fail(v.Key, v.Code, deleteCodeErr(v.Code, v.Message))
+		}
+	}
+
+	return res, res.Err()
+}
+
+func (u *UploaderObs) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+func (u *UploaderObs) ListObjects(ctx context.Context, prefix string, fn func(key string) error) error {
+	input := &obs.ListObjectsInput{}
+	input.Bucket = u.bucket
+	input.Prefix = prefix
+	input.MaxKeys = maxDeleteBatch
+
+	for {
+		// obs 的列举接口不支持 context，每页开始前检查
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		output, err := u.client.ListObjects(input)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range output.Contents {
+			if err = fn(v.Key); err != nil {
+				return err
+			}
+		}
+
+		if !output.IsTruncated || len(output.Contents) == 0 {
+			return nil
+		}
+
+		// 未指定分隔符时 NextMarker 可能为空，以本页最后一个对象继续
+		input.Marker = output.NextMarker
+		if input.Marker == "" {
+			input.Marker = output.Contents[len(output.Contents)-1].Key
+		}
+	}
+}
+
+// MoveObject obs 复制不支持禁止覆盖，WithNoOverwrite 时先检查目标再复制，并发时仍可能覆盖
+func (u *UploaderObs) MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error {
+	aclInput := &obs.GetObjectAclInput{}
+	aclInput.Bucket = u.bucket
+	aclInput.Key = src
+
+	acl, err := u.client.GetObjectAcl(aclInput)
+	if err != nil {
+		return obsNotFoundErr(err)
+	}
+
+	if newObjectOptions(opts...).NoOverwrite {
+		exists, err := u.Exists(ctx, dst)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ObjectExistsErr
+		}
+	}
+
+	if _, err = u.copyObject(ctx, src, "", dst); err != nil {
+		return err
+	}
+
+	// 复制后的对象为默认权限，重新设置源对象的访问权限
+	setAclInput := &obs.SetObjectAclInput{}
+	setAclInput.Bucket = u.bucket
+	setAclInput.Key = dst
+	setAclInput.AccessControlPolicy = acl.AccessControlPolicy
+	if _, err = u.client.SetObjectAcl(setAclInput); err != nil {
+		return err
+	}
+
+	deleteInput := &obs.DeleteObjectInput{}
+	deleteInput.Bucket = u.bucket
+	deleteInput.Key = src
+
+	_, err = u.client.DeleteObject(deleteInput)
+	return err
+}
+
+// obsMaxCopySize obs 单次复制的对象上限
+const obsMaxCopySize = 5 << 30
+
+// copyObject 服务端复制，srcVersionID 为空时复制最新版本，返回新对象的版本号
+func (u *UploaderObs) copyObject(ctx context.Context, src, srcVersionID, dst string) (string, error) {
+	metaInput := &obs.GetObjectMetadataInput{}
+	metaInput.Bucket = u.bucket
+	metaInput.Key = src
+	metaInput.VersionId = srcVersionID
+	if u.sse.isSSEC() {
+		metaInput.SseHeader = obsSseHeader(u.sse)
+	}
+
+	meta, err := u.client.GetObjectMetadata(metaInput)
+	if err != nil {
+		return "", obsNotFoundErr(err)
+	}
+
+	if meta.ContentLength > obsMaxCopySize {
+		return u.multipartCopy(ctx, src, srcVersionID, dst, meta)
+	}
+
+	input := &obs.CopyObjectInput{}
+	input.Bucket = u.bucket
+	input.Key = dst
+	input.CopySourceBucket = u.bucket
+	input.CopySourceKey = src
+	input.CopySourceVersionId = srcVersionID
+	input.SseHeader = obsSseHeader(u.sse)
+	// SSE-C 对象复制时需同时提供源对象的密钥
+	if u.sse.isSSEC() {
+		input.SourceSseHeader = obsSseHeader(u.sse)
+	}
+
+	output, err := u.client.CopyObject(input)
+	if err != nil {
+		return "", obsNotFoundErr(err)
+	}
+
+	return output.VersionId, nil
+}
+
+// multipartCopy 超过单次复制上限时分段复制，重新设置源对象的属性及标签，标签接口不支持版本，使用最新版本的标签
+func (u *UploaderObs) multipartCopy(ctx context.Context, src, srcVersionID, dst string, meta *obs.GetObjectMetadataOutput) (string, error) {
+	tags, err := u.GetObjectTags(ctx, src)
+	if err != nil {
+		return "", err
+	}
+
+	inputInit := &obs.InitiateMultipartUploadInput{}
+	inputInit.Bucket = u.bucket
+	inputInit.Key = dst
+	inputInit.HttpHeader = meta.HttpHeader
+	inputInit.Metadata = meta.Metadata
+	inputInit.SseHeader = obsSseHeader(u.sse)
+
+	outputInit, err := u.client.InitiateMultipartUpload(inputInit, obsTaggingHeader(tags))
+	if err != nil {
+		return "", errors.New("init multipart upload err: " + err.Error())
+	}
+
+	uploadId := outputInit.UploadId
+
+	size := meta.ContentLength
+	var parts []obs.Part
+	for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+copyPartSize {
+		input := &obs.CopyPartInput{}
+		input.Bucket = u.bucket
+		input.Key = dst
+		input.UploadId = uploadId
+		input.PartNumber = n
+		input.CopySourceBucket = u.bucket
+		input.CopySourceKey = src
+		input.CopySourceVersionId = srcVersionID
+		input.CopySourceRangeStart = offset
+		input.CopySourceRangeEnd = offset + min(copyPartSize, size-offset) - 1
+		// SSE-C 每个段都需要携带目标及源对象的密钥
+		if u.sse.isSSEC() {
+			input.SseHeader = obsSseHeader(u.sse)
+			input.SourceSseHeader = obsSseHeader(u.sse)
+		}
+
+		output, err := u.client.CopyPart(input)
+		if err != nil {
+			u.abortMultipartUpload(dst, uploadId)
+			return "", obsNotFoundErr(err)
+		}
+		parts = append(parts, obs.Part{PartNumber: n, ETag: output.ETag})
+	}
+
+	inputComplete := &obs.CompleteMultipartUploadInput{}
+	inputComplete.Bucket = u.bucket
+	inputComplete.Key = dst
+	inputComplete.UploadId = uploadId
+	inputComplete.Parts = parts
+	outputComplete, err := u.client.CompleteMultipartUpload(inputComplete)
+	if err != nil {
+		u.abortMultipartUpload(dst, uploadId)
+		return "", errors.New("complete multipart upload err: " + err.Error())
+	}
+
+	return outputComplete.VersionId, nil
+}
+
+func (u *UploaderObs) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	input := &obs.ListVersionsInput{}
 	input.Bucket = u.bucket
-	// 指定删除对象
-	objects := make([]obs.ObjectToDelete, len(path))
-	for k, v := range path {
-		objects[k] = obs.ObjectToDelete{Key: v, VersionId: ""}
+	input.Prefix = path
+	input.MaxKeys = maxDeleteBatch
+
+	var versions []ObjectVersion
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		output, err := u.client.ListVersions(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range output.Versions {
+			if v.Key == path {
+				versions = append(versions, ObjectVersion{
+					Path:         v.Key,
+					VersionID:    v.VersionId,
+					Size:         v.Size,
+					ETag:         strings.Trim(v.ETag, `"`),
+					IsLatest:     v.IsLatest,
+					LastModified: v.LastModified,
+				})
+			}
+		}
+		for _, v := range output.DeleteMarkers {
+			if v.Key == path {
+				versions = append(versions, ObjectVersion{
+					Path:           v.Key,
+					VersionID:      v.VersionId,
+					IsLatest:       v.IsLatest,
+					IsDeleteMarker: true,
+					LastModified:   v.LastModified,
+				})
+			}
+		}
+
+		// 下一页已不是该对象时停止
+		if !output.IsTruncated || output.NextKeyMarker != path {
+			break
+		}
+		input.KeyMarker, input.VersionIdMarker = output.NextKeyMarker, output.NextVersionIdMarker
 	}
 
-	input.Objects = objects[:]
-	input.Quiet = true
-	// 删除对象
-	_, err := u.client.DeleteObjects(input)
+	sortVersions(versions)
+
+	return versions, nil
+}
 
+func (u *UploaderObs) DeleteVersion(ctx context.Context, path, versionID string) error {
+	input := &obs.DeleteObjectInput{}
+	input.Bucket = u.bucket
+	input.Key = path
+	input.VersionId = versionID
+
+	_, err := u.client.DeleteObject(input)
 	return err
 }
+
+func (u *UploaderObs) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	return u.copyObject(ctx, path, versionID, path)
+}
+
+func (u *UploaderObs) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Tagging: true, ACL: true}
+}
+
+// obsRangeHeader SDK 的 RangeStart、RangeEnd 不支持读取到末尾及单字节范围，直接设置请求头，未指定范围时返回 nil
+func obsRangeHeader(rangeHeader string) interface{} {
+	if rangeHeader == "" {
+		return nil
+	}
+
+	return obs.WithCustomHeader("Range", rangeHeader)
+}
+
+// contextReader 读取前检查 ctx，ctx 取消后读取失败
+type contextReader struct {
+	readSeekerAt
+	ctx context.Context
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.readSeekerAt.Read(p)
+}
+
+func (r *contextReader) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.readSeekerAt.ReadAt(p, off)
+}
+
+// obsContextReader obs SDK 不支持 context，通过上传内容在 ctx 取消后中断请求
+func obsContextReader(ctx context.Context, r readSeekerAt) readSeekerAt {
+	return &contextReader{readSeekerAt: r, ctx: ctx}
+}
+
+// obsTaggingHeader 上传时设置标签的请求头，没有标签时返回 nil，SDK 会忽略
+func obsTaggingHeader(tags map[string]string) interface{} {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return obs.WithCustomHeader("x-obs-tagging", encodeTags(tags))
+}
+
+func (u *UploaderObs) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	body, err := u.taggingRequest(ctx, obs.HttpMethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagging obs.BucketTagging
+	if err = xml.Unmarshal(body, &tagging); err != nil {
+		return nil, errors.New("decode tagging " + path + ", err: " + err.Error())
+	}
+
+	tags := make(map[string]string, len(tagging.Tags))
+	for _, v := range tagging.Tags {
+		tags[v.Key] = v.Value
+	}
+
+	return tags, nil
+}
+
+func (u *UploaderObs) SetObjectTags(ctx context.Context, path string, tags map[string]string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	tagging := obs.BucketTagging{Tags: make([]obs.Tag, 0, len(tags))}
+	for k, v := range tags {
+		tagging.Tags = append(tagging.Tags, obs.Tag{Key: k, Value: v})
+	}
+
+	body, err := xml.Marshal(tagging)
+	if err != nil {
+		return err
+	}
+
+	_, err = u.taggingRequest(ctx, obs.HttpMethodPut, path, body)
+
+	return err
+}
+
+func (u *UploaderObs) DeleteObjectTags(ctx context.Context, path string) error {
+	_, err := u.taggingRequest(ctx, obs.HttpMethodDelete, path, nil)
+
+	return err
+}
+
+// taggingRequest SDK 未提供对象标签接口，通过签名 URL 直接请求 tagging 子资源
+func (u *UploaderObs) taggingRequest(ctx context.Context, method obs.HttpMethodType, path string, body []byte) ([]byte, error) {
+	input := &obs.CreateSignedUrlInput{
+		Method:      method,
+		Bucket:      u.bucket,
+		Key:         path,
+		SubResource: obs.SubResourceTagging,
+		Expires:     300,
+	}
+	if body != nil {
+		sum := md5.Sum(body)
+		input.Headers = map[string]string{
+			"Content-Type": "application/xml",
+			"Content-MD5":  base64.StdEncoding.EncodeToString(sum[:]),
+		}
+	}
+
+	output, err := u.client.CreateSignedUrl(input)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, string(method), output.SignedUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = output.ActualSignedRequestHeaders.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ObjectNotFoundErr
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%s tagging %s, status: %d, body: %s", method, path, resp.StatusCode, data)
+	}
+
+	return data, nil
+}
+
+func (u *UploaderObs) SetACL(ctx context.Context, path string, acl ACL) error {
+	if err := acl.validate(); err != nil {
+		return err
+	}
+
+	input := &obs.SetObjectAclInput{}
+	input.Bucket = u.bucket
+	input.Key = path
+	input.ACL = obs.AclType(acl)
+
+	_, err := u.client.SetObjectAcl(input)
+
+	return obsNotFoundErr(err)
+}
@@ -2,11 +2,15 @@ package file_storage
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/minio/minio-go/v7/pkg/s3utils"
 	"github.com/qiuyier/file-storage/pkg/util"
-	"mime/multipart"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,12 +21,25 @@ type UploaderOssConfig struct {
 	BucketName      string
 	Path            string
 	Domain          string
+	Overwrite       OverwritePolicy
+	// SSE 服务端加密
+	SSE *ServerSideEncryption
+	// ACL 默认访问权限，为空时继承存储桶设置
+	ACL ACL
+	// URLExpires 私有对象签名 URL 的有效期，默认 1 小时
+	URLExpires time.Duration
+	// DisableSSL Domain 及 EndPoint 未带协议头时使用 http，默认 https
+	DisableSSL bool
 }
 
 type UploaderOss struct {
-	bucket *oss.Bucket
-	path   string
-	domain string
+	bucket     *oss.Bucket
+	path       string
+	url        urlBuilder
+	overwrite  OverwritePolicy
+	sse        *ServerSideEncryption
+	acl        ACL
+	urlExpires time.Duration
 }
 
 func NewUploaderOss(config UploaderOssConfig) (uploader *UploaderOss, err error) {
@@ -37,33 +54,97 @@ func NewUploaderOss(config UploaderOssConfig) (uploader *UploaderOss, err error)
 	}
 
 	uploader = &UploaderOss{
-		bucket: bucket,
-		path:   config.Path,
-		domain: config.Domain,
+		bucket:     bucket,
+		path:       config.Path,
+		url:        newURLBuilder(ossDomain(config), !config.DisableSSL),
+		overwrite:  config.Overwrite,
+		sse:        config.SSE,
+		acl:        config.ACL,
+		urlExpires: urlExpires(config.URLExpires),
 	}
 
 	return
 }
 
-func (u *UploaderOss) Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error) {
-	path = util.GenName(u.path, file.Filename, randomly)
+func (u *UploaderOss) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	// 长度未知时无法计算整体 Content-MD5，改为逐个分片读入内存后分片上传
+	if file.Size() < 0 {
+		return u.MultipartUpload(ctx, file, randomly, streamPartSize, opts...)
+	}
+
+	o := newUploadOptions(opts...)
+	policy := o.overwritePolicy(u.overwrite)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
+	path := util.GenName(u.path, file.Name(), randomly)
 
 	if err = s3utils.CheckValidObjectName(path); err != nil {
-		return "", "", err
+		return res, err
+	}
+
+	path, err = resolvePath(ctx, u.Exists, path, policy)
+	if err != nil {
+		return res, err
 	}
 
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	var respHeader http.Header
+	options := append(ossOptions(o, policy, acl), ossSSEOptions(sse)...)
+	options = append(options, oss.GetResponseHeader(&respHeader), oss.WithContext(ctx))
+	// 限速包装后 SDK 无法识别文件类型获取长度，显式设置
+	if file.Size() >= 0 {
+		options = append(options, oss.ContentLength(file.Size()))
+	}
+	if progress != nil {
+		options = append(options, oss.Progress(&ossProgress{tracker: progress}))
+	}
+
+	// 上传的同时计算校验值，上传后与服务端返回的 crc64 比较
+	body := newChecksumReader(throttle(ctx, fd, o.RateLimiters))
+	err = u.bucket.PutObject(path, body, options...)
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, ossExistsErr(err)
+	}
+	checksum := body.Sum()
+
+	if err = verifyCRC64(respHeader.Get(oss.HTTPHeaderOssCRC64), checksum.CRC64); err != nil {
+		return res, err
 	}
 
-	err = u.bucket.PutObject(path, fd)
+	progress.completed()
+
+	fileUrl, err := u.fileURL(path, acl)
 	if err != nil {
-		return "", "", err
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: oss.GetVersionId(respHeader),
 	}
-	fileUrl = util.Join(u.domain, path)
 
 	return
 }
@@ -72,59 +153,517 @@ func (u *UploaderOss) GetUploaderType() string {
 	return AliYun
 }
 
-func (u *UploaderOss) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error) {
+func (u *UploaderOss) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	o := newUploadOptions(opts...)
+	policy := o.overwritePolicy(u.overwrite)
+
+	sse, err := o.serverSideEncryption(u.sse)
+	if err != nil {
+		return res, err
+	}
+	if err = validateTags(o.Tags); err != nil {
+		return res, err
+	}
+
+	acl, err := o.acl(u.acl)
+	if err != nil {
+		return res, err
+	}
+
 	// 上传路径
-	path = util.GenName(u.path, file.Filename, randomly)
+	path := util.GenName(u.path, file.Name(), randomly)
+
+	path, err = resolvePath(ctx, u.Exists, path, policy)
+	if err != nil {
+		return res, err
+	}
+
+	// 获取文件信息
+	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
+	defer fd.Close()
+
+	o.resolveContentType(fd, file.Name())
+
+	chunkSize = chunkSize * 1024 * 1024
+	chunks, err := newChunkReader(fd, file.Size(), int64(chunkSize))
+	if err != nil {
+		return res, err
+	}
 
 	// 指定过期时间。
 	expires := time.Now().Add(time.Minute * 3)
 	// 如果需要在初始化分片时设置请求头，请参考以下示例代码。
-	options := []oss.Option{
+	options := append([]oss.Option{
 		oss.MetadataDirective(oss.MetaReplace),
 		oss.Expires(expires),
-	}
+	}, ossOptions(o, policy, acl)...)
+	options = append(options, ossSSEOptions(sse)...)
 
 	// 初始化一个分片上传事件。
 	v, err := u.bucket.InitiateMultipartUpload(path, options...)
 	if err != nil {
-		return "", "", err
+		return res, err
 	}
 
-	// 获取文件信息
-	fd, err := file.Open()
-	defer fd.Close()
+	progress := newProgressTracker(o.Progress, file.Size())
+
+	// 上传分片，同时计算整体校验值
+	w := newChecksumWriter()
+	var parts []oss.UploadPart
+	for {
+		chunk, err := chunks.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = u.bucket.AbortMultipartUpload(v)
+			return res, err
+		}
+
+		partMD5, err := partChecksum(chunk.Buf, w)
+		if err != nil {
+			_ = u.bucket.AbortMultipartUpload(v)
+			return res, err
+		}
+
+		partOptions := append(ossSSECOptions(sse), oss.ContentMD5(base64.StdEncoding.EncodeToString(partMD5)), oss.ContentLength(chunk.Size), oss.WithContext(ctx))
+		if progress != nil {
+			partOptions = append(partOptions, oss.Progress(&ossProgress{tracker: progress}))
+		}
 
+		part, err := u.bucket.UploadPart(v, throttle(ctx, chunk.Buf, o.RateLimiters), chunk.Size, chunk.Number, partOptions...)
+		if err != nil {
+			_ = u.bucket.AbortMultipartUpload(v)
+			return res, err
+		}
+		parts = append(parts, part)
+		progress.partCompleted(chunk.Number, 0)
+
+	}
+	checksum := w.Sum()
+
+	// 步骤3：完成分片上传。
+	var respHeader http.Header
+	_, err = u.bucket.CompleteMultipartUpload(v, parts, oss.ForbidOverWrite(policy != OverwriteAllow), oss.GetResponseHeader(&respHeader))
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		_ = u.bucket.AbortMultipartUpload(v)
+		return res, ossExistsErr(err)
 	}
 
-	chunkSize = chunkSize * 1024 * 1024
-	chunks, err := util.SplitFileByPartSize(fd, file.Size, int64(chunkSize))
+	if err = verifyCRC64(respHeader.Get(oss.HTTPHeaderOssCRC64), checksum.CRC64); err != nil {
+		return res, err
+	}
+
+	progress.completed()
+
+	fileUrl, err := u.fileURL(path, acl)
+	if err != nil {
+		return res, err
+	}
+
+	res = ObjectResult{
+		Path:      path,
+		FileUrl:   fileUrl,
+		Checksum:  checksum,
+		VersionID: oss.GetVersionId(respHeader),
+	}
+
+	return
+}
+
+func (u *UploaderOss) Exists(ctx context.Context, path string) (bool, error) {
+	return u.bucket.IsObjectExist(path)
+}
+
+// ossOptions 将上传选项转换为 oss 请求头，非覆盖策略下由服务端保证不覆盖已有对象
+func ossOptions(o *UploadOptions, policy OverwritePolicy, acl ACL) []oss.Option {
+	options := []oss.Option{
+		oss.ContentType(o.ContentType),
+		oss.ForbidOverWrite(policy != OverwriteAllow),
+	}
+
+	if o.CacheControl != "" {
+		options = append(options, oss.CacheControl(o.CacheControl))
+	}
+	if o.ContentDisposition != "" {
+		options = append(options, oss.ContentDisposition(o.ContentDisposition))
+	}
+	if o.ContentEncoding != "" {
+		options = append(options, oss.ContentEncoding(o.ContentEncoding))
+	}
+	for k, v := range o.Metadata {
+		options = append(options, oss.Meta(k, v))
+	}
+	if len(o.Tags) > 0 {
+		options = append(options, oss.SetTagging(ossTagging(o.Tags)))
+	}
+	if acl != ACLDefault {
+		options = append(options, oss.ObjectACL(oss.ACLType(acl)))
+	}
+
+	return options
+}
+
+// ossDomain 未配置 Domain 时使用存储桶的外网访问域名
+func ossDomain(config UploaderOssConfig) string {
+	if config.Domain != "" {
+		return config.Domain
+	}
+
+	return virtualHostedDomain(config.BucketName, config.EndPoint)
+}
+
+// fileURL 私有对象返回带有效期的签名 URL
+func (u *UploaderOss) fileURL(path string, acl ACL) (string, error) {
+	if acl != ACLPrivate {
+		return u.url.build(path), nil
+	}
+
+	return u.bucket.SignURL(path, oss.HTTPGet, int64(u.urlExpires/time.Second))
+}
+
+func ossTagging(tags map[string]string) oss.Tagging {
+	tagging := oss.Tagging{Tags: make([]oss.Tag, 0, len(tags))}
+	for k, v := range tags {
+		tagging.Tags = append(tagging.Tags, oss.Tag{Key: k, Value: v})
+	}
+
+	return tagging
+}
+
+func (u *UploaderOss) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	header, err := u.bucket.GetObjectDetailedMeta(path, u.objectOptions(ctx, opts)...)
+	if err != nil {
+		return ObjectInfo{}, ossNotFoundErr(err)
+	}
+
+	return headerObjectInfo(path, header, "x-oss-meta-"), nil
+}
+
+// ossProgress 适配 oss 的进度回调
+type ossProgress consumedTracker
+
+func (p *ossProgress) ProgressChanged(event *oss.ProgressEvent) {
+	(*consumedTracker)(p).update(event.ConsumedBytes)
+}
+
+// ossSSEOptions 将服务端加密配置转换为 oss 请求头
+func ossSSEOptions(sse *ServerSideEncryption) []oss.Option {
+	if sse == nil {
+		return nil
+	}
+
+	switch sse.Algorithm {
+	case SSEKMS:
+		options := []oss.Option{oss.ServerSideEncryption("KMS")}
+		if sse.KMSKeyID != "" {
+			options = append(options, oss.ServerSideEncryptionKeyID(sse.KMSKeyID))
+		}
+		return options
+	case SSEC:
+		return ossSSECOptions(sse)
+	default:
+		return []oss.Option{oss.ServerSideEncryption("AES256")}
+	}
+}
+
+// ossSSECOptions SSE-C 的密钥请求头，上传分片及下载时也需要携带
+func ossSSECOptions(sse *ServerSideEncryption) []oss.Option {
+	if !sse.isSSEC() {
+		return nil
+	}
+
+	return []oss.Option{
+		oss.SSECAlgorithm("AES256"),
+		oss.SSECKey(sse.customerKey()),
+		oss.SSECKeyMd5(sse.customerKeyMD5()),
+	}
+}
+
+func (u *UploaderOss) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	rangeHeader, err := newObjectOptions(opts...).rangeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	options := u.objectOptions(ctx, opts)
+	if rangeHeader != "" {
+		// 默认范围不合法时返回整个对象，standard 模式下返回 416
+		options = append(options, oss.NormalizedRange(strings.TrimPrefix(rangeHeader, "bytes=")), oss.RangeBehavior("standard"))
+	}
+
+	body, err := u.bucket.GetObject(path, options...)
+	if err != nil {
+		return nil, ossNotFoundErr(err)
+	}
+
+	return body, nil
+}
+
+// objectOptions 读取对象时的请求选项
+func (u *UploaderOss) objectOptions(ctx context.Context, opts []ObjectOption) []oss.Option {
+	options := append(ossSSECOptions(u.sse), oss.WithContext(ctx))
+	if o := newObjectOptions(opts...); o.VersionID != "" {
+		options = append(options, oss.VersionId(o.VersionID))
+	}
+
+	return options
+}
+
+func ossNotFoundErr(err error) error {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		switch serviceErr.StatusCode {
+		case http.StatusNotFound:
+			return ObjectNotFoundErr
+		case http.StatusRequestedRangeNotSatisfiable:
+			return InvalidRangeErr
+		}
+	}
+
+	return err
+}
+
+func ossExistsErr(err error) error {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.Code == "FileAlreadyExists" {
+		return ObjectExistsErr
+	}
+
+	return err
+}
+
+func (u *UploaderOss) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
+	for _, chunk := range chunkPaths(path, maxDeleteBatch) {
+		result, err := u.bucket.DeleteObjects(chunk, oss.DeleteObjectsQuiet(false), oss.WithContext(ctx))
+		if err != nil {
+			res.failAll(chunk, err)
+			continue
+		}
+
+		// oss 只返回删除成功的对象，未出现在结果中的对象视为失败
+		deleted := make(map[string]struct{}, len(result.DeletedObjects))
+		for _, v := range result.DeletedObjects {
+			deleted[v] = struct{}{}
+			res.Deleted = append(res.Deleted, v)
+		}
+		for _, v := range chunk {
+			if _, ok := deleted[v]; !ok {
+				res.fail(v, "", NotDeletedErr)
+			}
+		}
+	}
+
+	return res, res.Err()
+}
+
+func (u *UploaderOss) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+func (u *UploaderOss) ListObjects(ctx context.Context, prefix string, fn func(key string) error) error {
+	token := ""
+	for {
+		result, err := u.bucket.ListObjectsV2(oss.Prefix(prefix), oss.ContinuationToken(token), oss.MaxKeys(maxDeleteBatch), oss.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		for _, v := range result.Objects {
+			if err = fn(v.Key); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (u *UploaderOss) MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error {
+	acl, err := u.bucket.GetObjectACL(src, oss.WithContext(ctx))
+	if err != nil {
+		return ossNotFoundErr(err)
+	}
+
+	if _, err = u.copyObject(ctx, src, "", dst, newObjectOptions(opts...).NoOverwrite); err != nil {
+		return err
+	}
+
+	// 复制不保留对象的访问权限，default 表示继承存储桶
+	if acl.ACL != string(oss.ACLDefault) {
+		if err = u.bucket.SetObjectACL(dst, oss.ACLType(acl.ACL), oss.WithContext(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return u.bucket.DeleteObject(src, oss.WithContext(ctx))
+}
+
+// ossMaxCopySize oss 单次复制的对象上限
+const ossMaxCopySize = 1 << 30
+
+// copyObject 服务端复制，srcVersionID 为空时复制最新版本，返回新对象的版本号
+// forbidOverwrite 时由服务端保证不覆盖已有对象，已存在时返回 ObjectExistsErr
+func (u *UploaderOss) copyObject(ctx context.Context, src, srcVersionID, dst string, forbidOverwrite bool) (string, error) {
+	header, err := u.bucket.GetObjectDetailedMeta(src, u.objectOptions(ctx, []ObjectOption{WithVersionID(srcVersionID)})...)
+	if err != nil {
+		return "", ossNotFoundErr(err)
+	}
+
+	if size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64); size > ossMaxCopySize {
+		return u.multipartCopy(ctx, src, srcVersionID, dst, size, header, forbidOverwrite)
+	}
+
+	var respHeader http.Header
+	options := append(ossSSEOptions(u.sse), oss.ForbidOverWrite(forbidOverwrite), oss.WithContext(ctx), oss.GetResponseHeader(&respHeader))
+	if srcVersionID != "" {
+		options = append(options, oss.VersionId(srcVersionID))
+	}
+
+	if _, err = u.bucket.CopyObject(src, dst, options...); err != nil {
+		return "", ossExistsErr(ossNotFoundErr(err))
+	}
+
+	return oss.GetVersionId(respHeader), nil
+}
+
+// multipartCopy 超过单次复制上限时分片复制，重新设置源对象的属性及标签
+func (u *UploaderOss) multipartCopy(ctx context.Context, src, srcVersionID, dst string, size int64, header http.Header, forbidOverwrite bool) (string, error) {
+	options := append(ossSSEOptions(u.sse), oss.WithContext(ctx))
+	for k, v := range copyHeaders(header, "x-oss-meta-") {
+		options = append(options, oss.SetHeader(k, v[0]))
+	}
+
+	versionOptions := []oss.Option{oss.WithContext(ctx)}
+	if srcVersionID != "" {
+		versionOptions = append(versionOptions, oss.VersionId(srcVersionID))
+	}
+
+	tagging, err := u.bucket.GetObjectTagging(src, versionOptions...)
 	if err != nil {
-		return "", "", err
+		return "", ossNotFoundErr(err)
+	}
+	if len(tagging.Tags) > 0 {
+		options = append(options, oss.SetTagging(oss.Tagging{Tags: tagging.Tags}))
+	}
+
+	v, err := u.bucket.InitiateMultipartUpload(dst, options...)
+	if err != nil {
+		return "", err
 	}
 
-	// 上传分片。
 	var parts []oss.UploadPart
-	for _, chunk := range chunks {
-		part, err := u.bucket.UploadPart(v, chunk.Buf, chunk.Size, chunk.Number)
+	for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+copyPartSize {
+		part, err := u.bucket.UploadPartCopy(v, u.bucket.BucketName, src, offset, min(copyPartSize, size-offset), n, append(ossSSECOptions(u.sse), versionOptions...)...)
 		if err != nil {
 			_ = u.bucket.AbortMultipartUpload(v)
-			return "", "", err
+			return "", ossNotFoundErr(err)
 		}
 		parts = append(parts, part)
+	}
 
+	var respHeader http.Header
+	if _, err = u.bucket.CompleteMultipartUpload(v, parts, oss.ForbidOverWrite(forbidOverwrite), oss.WithContext(ctx), oss.GetResponseHeader(&respHeader)); err != nil {
+		_ = u.bucket.AbortMultipartUpload(v)
+		return "", ossExistsErr(err)
 	}
 
-	// 步骤3：完成分片上传。
-	_, _ = u.bucket.CompleteMultipartUpload(v, parts)
-	fileUrl = util.Join(u.domain, path)
+	return oss.GetVersionId(respHeader), nil
+}
 
-	return
+func (u *UploaderOss) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	keyMarker, versionIdMarker := "", ""
+	for {
+		result, err := u.bucket.ListObjectVersions(oss.Prefix(path), oss.KeyMarker(keyMarker), oss.VersionIdMarker(versionIdMarker), oss.MaxKeys(maxDeleteBatch), oss.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range result.ObjectVersions {
+			if v.Key == path {
+				versions = append(versions, ObjectVersion{
+					Path:         v.Key,
+					VersionID:    v.VersionId,
+					Size:         v.Size,
+					ETag:         strings.Trim(v.ETag, `"`),
+					IsLatest:     v.IsLatest,
+					LastModified: v.LastModified,
+				})
+			}
+		}
+		for _, v := range result.ObjectDeleteMarkers {
+			if v.Key == path {
+				versions = append(versions, ObjectVersion{
+					Path:           v.Key,
+					VersionID:      v.VersionId,
+					IsLatest:       v.IsLatest,
+					IsDeleteMarker: true,
+					LastModified:   v.LastModified,
+				})
+			}
+		}
+
+		// 下一页已不是该对象时停止
+		if !result.IsTruncated || result.NextKeyMarker != path {
+			break
+		}
+		keyMarker, versionIdMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
 }
 
-func (u *UploaderOss) DeleteObjects(ctx context.Context, path []string) error {
-	_, err := u.bucket.DeleteObjects(path, oss.DeleteObjectsQuiet(true))
+func (u *UploaderOss) DeleteVersion(ctx context.Context, path, versionID string) error {
+	return u.bucket.DeleteObject(path, oss.VersionId(versionID), oss.WithContext(ctx))
+}
 
-	return err
+func (u *UploaderOss) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	return u.copyObject(ctx, path, versionID, path, false)
+}
+
+func (u *UploaderOss) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Tagging: true, ACL: true}
+}
+
+func (u *UploaderOss) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	result, err := u.bucket.GetObjectTagging(path, oss.WithContext(ctx))
+	if err != nil {
+		return nil, ossNotFoundErr(err)
+	}
+
+	tags := make(map[string]string, len(result.Tags))
+	for _, v := range result.Tags {
+		tags[v.Key] = v.Value
+	}
+
+	return tags, nil
+}
+
+func (u *UploaderOss) SetObjectTags(ctx context.Context, path string, tags map[string]string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	return ossNotFoundErr(u.bucket.PutObjectTagging(path, ossTagging(tags), oss.WithContext(ctx)))
+}
+
+func (u *UploaderOss) DeleteObjectTags(ctx context.Context, path string) error {
+	return ossNotFoundErr(u.bucket.DeleteObjectTagging(path, oss.WithContext(ctx)))
+}
+
+func (u *UploaderOss) SetACL(ctx context.Context, path string, acl ACL) error {
+	if err := acl.validate(); err != nil {
+		return err
+	}
+
+	return ossNotFoundErr(u.bucket.SetObjectACL(path, oss.ACLType(acl), oss.WithContext(ctx)))
 }
@@ -2,11 +2,18 @@ package file_storage
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/qiniu/go-sdk/v7/auth"
 	"github.com/qiniu/go-sdk/v7/storage"
 	"github.com/qiuyier/file-storage/pkg/util"
-	"mime/multipart"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
 )
 
 type UploaderQiNiuConfig struct {
@@ -17,16 +24,25 @@ type UploaderQiNiuConfig struct {
 	Domain          string
 	UseSSL          bool
 	UseCdn          bool
+	Overwrite       OverwritePolicy
+	// ACL 存储空间的访问权限，七牛不支持对象级权限，为 ACLPrivate 时 FileUrl 为签名 URL
+	ACL ACL
+	// URLExpires 私有空间签名 URL 的有效期，默认 1 小时
+	URLExpires time.Duration
 }
 
 type UploaderQiNiu struct {
 	client        *storage.ResumeUploaderV2
 	bucketManager *storage.BucketManager
-	putPolicy     storage.PutPolicy
 	mac           *auth.Credentials
 	bucket        string
 	path          string
 	domain        string
+	url           urlBuilder
+	useSSL        bool
+	overwrite     OverwritePolicy
+	acl           ACL
+	urlExpires    time.Duration
 }
 
 func NewUploaderQiNiu(config UploaderQiNiuConfig) (uploader *UploaderQiNiu, err error) {
@@ -49,70 +65,461 @@ func NewUploaderQiNiu(config UploaderQiNiuConfig) (uploader *UploaderQiNiu, err
 	uploader = &UploaderQiNiu{
 		client:        client,
 		bucketManager: bucketManager,
-		putPolicy: storage.PutPolicy{
-			Scope: config.BucketName,
-		},
-		bucket: config.BucketName,
-		mac:    mac,
-		path:   config.Path,
-		domain: config.Domain,
+		bucket:        config.BucketName,
+		mac:           mac,
+		path:          config.Path,
+		domain:        config.Domain,
+		url:           newURLBuilder(config.Domain, config.UseSSL),
+		useSSL:        config.UseSSL,
+		overwrite:     config.Overwrite,
+		acl:           config.ACL,
+		urlExpires:    urlExpires(config.URLExpires),
 	}
 
 	return
 }
 
-func (u *UploaderQiNiu) Upload(ctx context.Context, file *multipart.FileHeader, randomly bool) (path, fileUrl string, err error) {
-	path = util.GenName(u.path, file.Filename, randomly)
+func (u *UploaderQiNiu) Upload(ctx context.Context, file Source, randomly bool, opts ...UploadOption) (res ObjectResult, err error) {
+	return u.put(ctx, file, randomly, 0, opts...)
+}
+
+func (u *UploaderQiNiu) GetUploaderType() string {
+	return QiNiu
+}
+
+func (u *UploaderQiNiu) MultipartUpload(ctx context.Context, file Source, randomly bool, chunkSize int, opts ...UploadOption) (res ObjectResult, err error) {
+	return u.put(ctx, file, randomly, int64(chunkSize*1024*1024), opts...)
+}
+
+// put 七牛分片上传 v2 接口同时支持普通上传和分片上传，partSize 为 0 时使用 SDK 默认的 4M
+func (u *UploaderQiNiu) put(ctx context.Context, file Source, randomly bool, partSize int64, opts ...UploadOption) (res ObjectResult, err error) {
+	o := newUploadOptions(opts...)
+	if err = checkQiNiuOptions(o); err != nil {
+		return res, err
+	}
+	policy := o.overwritePolicy(u.overwrite)
+
+	path := util.GenName(u.path, file.Name(), randomly)
+
+	path, err = resolvePath(ctx, u.Exists, path, policy)
+	if err != nil {
+		return res, err
+	}
 
 	fd, err := file.Open()
+	if err != nil {
+		return res, errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
 	defer fd.Close()
 
+	o.resolveContentType(fd, file.Name())
+
+	etag := newQiNiuEtagWriter()
+	var checksum Checksum
+	if file.Size() >= 0 {
+		if checksum, err = computeChecksum(fd, etag); err != nil {
+			return res, errors.New("checksum file " + file.Name() + ", err: " + err.Error())
+		}
+	}
+
+	upToken := u.uploadToken(path, policy)
+
+	extra := &storage.RputV2Extra{
+		PartSize: partSize,
+		MimeType: o.ContentType,
+		Metadata: qiNiuMetadata(o.Metadata),
+	}
+
+	// 七牛 SDK 只在分片完成时回调，按分片大小计算进度
+	progress := newProgressTracker(o.Progress, file.Size())
+	if progress != nil {
+		blockSize := partSize
+		if blockSize == 0 {
+			blockSize = qiNiuBlockSize
+		}
+
+		extra.Notify = func(partNumber int64, ret *storage.UploadPartsRet) {
+			// 长度未知时已按读取的字节数计算进度
+			if file.Size() < 0 {
+				progress.partCompleted(int(partNumber), 0)
+				return
+			}
+			progress.partCompleted(int(partNumber), min(blockSize, file.Size()-(partNumber-1)*blockSize))
+		}
+	}
+
+	var ret storage.PutRet
+	if file.Size() < 0 {
+		// 长度未知时 SDK 按分片大小依次读入内存上传，校验值在读取的同时计算
+		w := newChecksumWriter()
+		writers := []io.Writer{w, etag}
+		if progress != nil {
+			writers = append(writers, progress)
+		}
+
+		body := io.TeeReader(throttle(ctx, fd, o.RateLimiters), io.MultiWriter(writers...))
+		err = u.client.PutWithoutSize(ctx, &ret, upToken, path, body, extra)
+		checksum = w.Sum()
+	} else {
+		err = u.client.Put(ctx, &ret, upToken, path, throttle(ctx, fd, o.RateLimiters), file.Size(), extra)
+	}
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return res, qiNiuExistsErr(err)
+	}
+
+	// 分片大小不是 4M 时七牛使用新版 hash 算法，无法在本地复现
+	if (partSize == 0 || partSize == qiNiuBlockSize) && ret.Hash != "" && ret.Hash != etag.Sum() {
+		return res, fmt.Errorf("%w: hash %s, expect %s", ChecksumMismatchErr, ret.Hash, etag.Sum())
 	}
 
-	upToken := u.putPolicy.UploadToken(u.mac)
+	progress.completed()
 
-	err = u.client.Put(ctx, storage.PutRet{}, upToken, path, fd, file.Size, &storage.RputV2Extra{})
-	fileUrl = util.Join(u.domain, path)
+	res = ObjectResult{
+		Path:     path,
+		FileUrl:  u.fileURL(path),
+		Checksum: checksum,
+	}
 
 	return
 }
 
-func (u *UploaderQiNiu) GetUploaderType() string {
-	return QiNiu
+func (u *UploaderQiNiu) Exists(ctx context.Context, path string) (bool, error) {
+	_, err := u.bucketManager.Stat(u.bucket, path)
+	if err != nil {
+		var errInfo *storage.ErrorInfo
+		// 612 文件不存在
+		if errors.As(err, &errInfo) && errInfo.Code == 612 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (u *UploaderQiNiu) MultipartUpload(ctx context.Context, file *multipart.FileHeader, randomly bool, chunkSize int) (path, fileUrl string, err error) {
-	path = util.GenName(u.path, file.Filename, randomly)
+func (u *UploaderQiNiu) StatObject(ctx context.Context, path string, opts ...ObjectOption) (ObjectInfo, error) {
+	if newObjectOptions(opts...).VersionID != "" {
+		return ObjectInfo{}, qiniuVersioningErr
+	}
 
-	fd, err := file.Open()
-	defer fd.Close()
+	stat, err := u.bucketManager.Stat(u.bucket, path)
+	if err != nil {
+		var errInfo *storage.ErrorInfo
+		if errors.As(err, &errInfo) && errInfo.Code == 612 {
+			return ObjectInfo{}, ObjectNotFoundErr
+		}
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Path:        path,
+		Size:        stat.Fsize,
+		ETag:        stat.Hash,
+		ContentType: stat.MimeType,
+		// PutTime 单位为 100 纳秒
+		LastModified: time.Unix(0, stat.PutTime*100),
+		Metadata:     lowerMetadata(stat.MetaData, "x-qn-meta-"),
+	}, nil
+}
+
+func (u *UploaderQiNiu) GetObject(ctx context.Context, path string, opts ...ObjectOption) (io.ReadCloser, error) {
+	o := newObjectOptions(opts...)
+	if o.VersionID != "" {
+		return nil, qiniuVersioningErr
+	}
+
+	rangeHeader, err := o.rangeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	url := storage.MakePrivateURLv2(u.mac, u.downloadDomain(), path, time.Now().Add(time.Hour).Unix())
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", "", errors.New("open file " + file.Filename + ", err: " + err.Error())
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
 	}
 
-	upToken := u.putPolicy.UploadToken(u.mac)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
 
-	err = u.client.Put(ctx, storage.PutRet{}, upToken, path, fd, file.Size, &storage.RputV2Extra{
-		PartSize: int64(chunkSize * 1024 * 1024),
-	})
-	fileUrl = util.Join(u.domain, path)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// 下载域名未处理 Range 时返回完整内容，在本地截取
+		return rangeReadCloser(resp.Body, o)
+	}
 
-	return
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ObjectNotFoundErr
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, InvalidRangeErr
+	}
+
+	return nil, fmt.Errorf("get object %s, status: %s", path, resp.Status)
 }
 
-func (u *UploaderQiNiu) DeleteObjects(ctx context.Context, path []string) error {
-	deleteOps := make([]string, 0, len(path))
-	for _, key := range path {
-		deleteOps = append(deleteOps, storage.URIDelete(u.bucket, key))
+// fileURL 私有空间返回带有效期的签名 URL
+func (u *UploaderQiNiu) fileURL(path string) string {
+	if u.acl != ACLPrivate {
+		return u.url.build(path)
 	}
 
-	rets, err := u.bucketManager.Batch(deleteOps)
-	if len(rets) == 0 || err != nil {
-		return err
+	return storage.MakePrivateURLv2(u.mac, u.downloadDomain(), path, time.Now().Add(u.urlExpires).Unix())
+}
+
+// downloadDomain 下载域名需带协议头，配置中未填写时按 UseSSL 补全
+func (u *UploaderQiNiu) downloadDomain() string {
+	return withScheme(u.domain, u.useSSL)
+}
+
+// uploadToken 生成上传凭证，只有指定 key 的凭证才允许覆盖同名文件
+func (u *UploaderQiNiu) uploadToken(path string, policy OverwritePolicy) string {
+	putPolicy := storage.PutPolicy{
+		Scope: u.bucket,
+	}
+
+	if policy == OverwriteAllow {
+		putPolicy.Scope = u.bucket + ":" + path
+	} else {
+		putPolicy.InsertOnly = 1
+	}
+
+	return putPolicy.UploadToken(u.mac)
+}
+
+// checkQiNiuOptions 七牛上传接口不支持设置 Cache-Control 等响应头，也不支持指定服务端加密
+func checkQiNiuOptions(o *UploadOptions) error {
+	if o.CacheControl != "" || o.ContentDisposition != "" || o.ContentEncoding != "" {
+		return fmt.Errorf("%w: qiniu driver does not support Cache-Control, Content-Disposition or Content-Encoding", UnsupportedOptionErr)
+	}
+
+	if o.SSE != nil {
+		return fmt.Errorf("%w: qiniu driver does not support server-side encryption", UnsupportedOptionErr)
+	}
+
+	if len(o.Tags) > 0 {
+		return qiniuTaggingErr
+	}
+
+	if o.ACL != ACLDefault {
+		return qiniuACLErr
 	}
 
 	return nil
 }
+
+// qiNiuMetadata 七牛自定义元数据需以 x-qn-meta- 开头
+func qiNiuMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	meta := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		meta["x-qn-meta-"+k] = v
+	}
+
+	return meta
+}
+
+func qiNiuExistsErr(err error) error {
+	var errInfo *storage.ErrorInfo
+	// 614 目标资源已存在
+	if errors.As(err, &errInfo) && errInfo.Code == 614 {
+		return ObjectExistsErr
+	}
+
+	return err
+}
+
+// qiNiuBlockSize 七牛 hash 算法的分块大小
+const qiNiuBlockSize = 4 * 1024 * 1024
+
+// qiNiuEtagWriter 计算七牛文件 hash：
+// 不超过 4M 时为 0x16 + sha1(内容)，否则为 0x96 + sha1(各 4M 块 sha1 拼接)，结果为 URL 安全的 base64
+type qiNiuEtagWriter struct {
+	block  hash.Hash
+	n      int
+	blocks []byte
+	count  int
+}
+
+func newQiNiuEtagWriter() *qiNiuEtagWriter {
+	return &qiNiuEtagWriter{block: sha1.New()}
+}
+
+func (w *qiNiuEtagWriter) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		m := qiNiuBlockSize - w.n
+		if m > len(p) {
+			m = len(p)
+		}
+
+		w.block.Write(p[:m])
+		w.n += m
+		p = p[m:]
+
+		if w.n == qiNiuBlockSize {
+			w.blocks = w.block.Sum(w.blocks)
+			w.block.Reset()
+			w.n = 0
+			w.count++
+		}
+	}
+
+	return written, nil
+}
+
+func (w *qiNiuEtagWriter) Sum() string {
+	blocks, count := w.blocks, w.count
+	if w.n > 0 || count == 0 {
+		blocks = w.block.Sum(blocks[:len(blocks):len(blocks)])
+		count++
+	}
+
+	sum := append([]byte{0x16}, blocks...)
+	if count > 1 {
+		h := sha1.Sum(blocks)
+		sum = append([]byte{0x96}, h[:]...)
+	}
+
+	return base64.URLEncoding.EncodeToString(sum)
+}
+
+// isQiNiuEtagV1 判断 hash 是否可由 qiNiuEtagWriter 复现，分片大小不是 4M 上传的对象使用新版 hash
+func isQiNiuEtagV1(etag string, size int64) bool {
+	sum, err := base64.URLEncoding.DecodeString(etag)
+	if err != nil || len(sum) != sha1.Size+1 {
+		return false
+	}
+
+	if size <= qiNiuBlockSize {
+		return sum[0] == 0x16
+	}
+
+	return sum[0] == 0x96
+}
+
+func (u *UploaderQiNiu) DeleteObjects(ctx context.Context, path []string) (res DeleteResult, err error) {
+	for _, chunk := range chunkPaths(path, maxDeleteBatch) {
+		deleteOps := make([]string, 0, len(chunk))
+		for _, key := range chunk {
+			deleteOps = append(deleteOps, storage.URIDelete(u.bucket, key))
+		}
+
+		rets, err := u.bucketManager.BatchWithContext(ctx, u.bucket, deleteOps)
+		if err != nil && len(rets) != len(chunk) {
+			res.failAll(chunk, err)
+			continue
+		}
+
+		for i, ret := range rets {
+			switch ret.Code {
+			// 612 文件不存在
+			case http.StatusOK, 612:
+				res.Deleted = append(res.Deleted, chunk[i])
+			case http.StatusUnauthorized, http.StatusForbidden:
+				res.fail(chunk[i], strconv.Itoa(ret.Code), AccessDeniedErr)
+			default:
+				res.fail(chunk[i], strconv.Itoa(ret.Code), errors.New(ret.Data.Error))
+			}
+		}
+	}
+
+	return res, res.Err()
+}
+
+func (u *UploaderQiNiu) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+func (u *UploaderQiNiu) ListObjects(ctx context.Context, prefix string, fn func(key string) error) error {
+	marker := ""
+	for {
+		ret, hasNext, err := u.bucketManager.ListFilesWithContext(ctx, u.bucket,
+			storage.ListInputOptionsPrefix(prefix),
+			storage.ListInputOptionsMarker(marker),
+			storage.ListInputOptionsLimit(maxDeleteBatch),
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range ret.Items {
+			if err = fn(v.Key); err != nil {
+				return err
+			}
+		}
+
+		if !hasNext {
+			return nil
+		}
+		marker = ret.Marker
+	}
+}
+
+// MoveObject 服务端直接移动，不受对象大小限制，WithNoOverwrite 时由服务端保证不覆盖
+func (u *UploaderQiNiu) MoveObject(ctx context.Context, src, dst string, opts ...ObjectOption) error {
+	force := !newObjectOptions(opts...).NoOverwrite
+	if err := u.bucketManager.Move(u.bucket, src, u.bucket, dst, force); err != nil {
+		var errInfo *storage.ErrorInfo
+		if errors.As(err, &errInfo) && errInfo.Code == 612 {
+			return ObjectNotFoundErr
+		}
+		return qiNiuExistsErr(err)
+	}
+
+	return nil
+}
+
+// qiniuVersioningErr 七牛不支持多版本
+var qiniuVersioningErr = fmt.Errorf("%w: qiniu driver does not support versioning", UnsupportedOptionErr)
+
+func (u *UploaderQiNiu) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	return nil, qiniuVersioningErr
+}
+
+func (u *UploaderQiNiu) DeleteVersion(ctx context.Context, path, versionID string) error {
+	return qiniuVersioningErr
+}
+
+func (u *UploaderQiNiu) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	return "", qiniuVersioningErr
+}
+
+// qiniuTaggingErr 七牛不支持对象标签
+var qiniuTaggingErr = fmt.Errorf("%w: qiniu driver does not support object tagging", UnsupportedOptionErr)
+
+func (u *UploaderQiNiu) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (u *UploaderQiNiu) GetObjectTags(ctx context.Context, path string) (map[string]string, error) {
+	return nil, qiniuTaggingErr
+}
+
+func (u *UploaderQiNiu) SetObjectTags(ctx context.Context, path string, tags map[string]string) error {
+	return qiniuTaggingErr
+}
+
+func (u *UploaderQiNiu) DeleteObjectTags(ctx context.Context, path string) error {
+	return qiniuTaggingErr
+}
+
+// qiniuACLErr 七牛只支持存储空间级别的访问权限
+var qiniuACLErr = fmt.Errorf("%w: qiniu driver does not support object acl", UnsupportedOptionErr)
+
+func (u *UploaderQiNiu) SetACL(ctx context.Context, path string, acl ACL) error {
+	return qiniuACLErr
+}
@@ -0,0 +1,266 @@
+package file_storage
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"slices"
+)
+
+const (
+	// defaultUploadField 默认的文件表单字段
+	defaultUploadField = "file"
+	// defaultMaxRequestSize 默认的请求体最大长度
+	defaultMaxRequestSize = 32 << 20
+	// defaultMaxMemory 解析表单时默认在内存中保留的最大长度
+	defaultMaxMemory = 8 << 20
+	// defaultMaxFiles 单次请求默认最多上传的文件数
+	defaultMaxFiles = 10
+)
+
+// UploadHandlerConfig 上传接口配置，零值字段使用默认值
+type UploadHandlerConfig struct {
+	// FieldNames 文件表单字段，同一字段可包含多个文件，默认 file
+	FieldNames []string
+	// MaxRequestSize 请求体最大长度，读取时即限制，超出时返回 413，默认 32M
+	MaxRequestSize int64
+	// MaxMemory 解析表单时内存中保留的最大长度，超出部分写入临时文件，默认 8M
+	MaxMemory int64
+	// MaxFiles 单次请求最多上传的文件数，默认 10
+	MaxFiles int
+	// RandomName 是否重新生成文件名
+	RandomName bool
+	// Workers 多个文件的上传并发数，默认与批量上传一致
+	Workers int
+	// Options 按请求生成上传选项，如按登录用户设置元数据
+	Options func(r *http.Request) []UploadOption
+	// Streaming 边读取请求体边上传，文件不会缓存到内存或临时文件，此时按顺序逐个上传，Workers、MaxMemory 不生效
+	Streaming bool
+	// IncludePath 响应中返回对象路径，本地驱动为服务器上的文件路径，默认不返回
+	IncludePath bool
+}
+
+// UploadHandler 接收 multipart/form-data 请求并上传其中的文件
+type UploadHandler struct {
+	uploader *Uploader
+	config   UploadHandlerConfig
+}
+
+// UploadResponse 上传接口的响应
+type UploadResponse struct {
+	// Files 与请求中的文件顺序一致
+	Files []UploadFileResponse `json:"files,omitempty"`
+	// Error 请求整体失败时的原因
+	Error string `json:"error,omitempty"`
+}
+
+// UploadFileResponse 单个文件的上传结果
+type UploadFileResponse struct {
+	FileName string        `json:"file_name"`
+	Result   *UploadResult `json:"result,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func NewUploadHandler(uploader *Uploader, config UploadHandlerConfig) *UploadHandler {
+	if len(config.FieldNames) == 0 {
+		config.FieldNames = []string{defaultUploadField}
+	}
+	if config.MaxRequestSize <= 0 {
+		config.MaxRequestSize = defaultMaxRequestSize
+	}
+	if config.MaxMemory <= 0 {
+		config.MaxMemory = defaultMaxMemory
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = defaultMaxFiles
+	}
+
+	return &UploadHandler{uploader: uploader, config: config}
+}
+
+// HandlerFunc 供只接受 http.HandlerFunc 的路由使用
+func (h *UploadHandler) HandlerFunc() http.HandlerFunc {
+	return h.ServeHTTP
+}
+
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		writeUploadResponse(w, http.StatusMethodNotAllowed, UploadResponse{Error: http.StatusText(http.StatusMethodNotAllowed)})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.config.MaxRequestSize)
+
+	status, resp := h.Process(r)
+	writeUploadResponse(w, status, resp)
+}
+
+// Process 解析请求并上传文件，返回响应状态码和响应体，供需要自行输出响应的框架使用
+// 全部成功时返回 200，部分失败时返回 207，全部失败时返回第一个错误对应的状态码
+func (h *UploadHandler) Process(r *http.Request) (int, UploadResponse) {
+	if h.config.Streaming {
+		return h.processStream(r)
+	}
+
+	if err := r.ParseMultipartForm(h.config.MaxMemory); err != nil {
+		return uploadErrorResponse(err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files, err := h.files(r.MultipartForm)
+	if err != nil {
+		return uploadErrorResponse(err)
+	}
+
+	var opts []UploadOption
+	if h.config.Options != nil {
+		opts = h.config.Options(r)
+	}
+
+	results := h.uploader.BatchUpload(r.Context(), files, h.config.RandomName, h.config.Workers, opts...)
+
+	return h.response(results)
+}
+
+// processStream 逐个读取请求体中的文件并上传，读取请求体失败时已上传的文件结果仍会返回
+func (h *UploadHandler) processStream(r *http.Request) (int, UploadResponse) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return uploadErrorResponse(err)
+	}
+
+	var opts []UploadOption
+	if h.config.Options != nil {
+		opts = h.config.Options(r)
+	}
+
+	count := 0
+	results, err := h.uploader.uploadParts(r.Context(), reader, h.config.RandomName, func(part *multipart.Part) (bool, error) {
+		if !slices.Contains(h.config.FieldNames, part.FormName()) {
+			return false, nil
+		}
+
+		if count++; count > h.config.MaxFiles {
+			return false, TooManyFilesErr
+		}
+
+		return true, nil
+	}, opts)
+	if err == nil && len(results) == 0 {
+		err = NoUploadFileErr
+	}
+	if err != nil {
+		status, resp := uploadErrorResponse(err)
+		_, files := h.response(results)
+		resp.Files = files.Files
+		return status, resp
+	}
+
+	return h.response(results)
+}
+
+// response 汇总各文件的上传结果
+func (h *UploadHandler) response(results []BatchResult) (int, UploadResponse) {
+	var (
+		resp     = UploadResponse{Files: make([]UploadFileResponse, len(results))}
+		firstErr error
+	)
+	for i, v := range results {
+		resp.Files[i].FileName = v.FileName
+		if v.Err != nil {
+			_, errResp := uploadErrorResponse(v.Err)
+			resp.Files[i].Error = errResp.Error
+			if firstErr == nil {
+				firstErr = v.Err
+			}
+			continue
+		}
+
+		result := v.Result
+		if !h.config.IncludePath {
+			result.Path = ""
+		}
+		resp.Files[i].Result = &result
+	}
+
+	switch {
+	case firstErr == nil:
+		return http.StatusOK, resp
+	case len(results) > 1 && resp.hasResult():
+		return http.StatusMultiStatus, resp
+	default:
+		return uploadErrorStatus(firstErr), resp
+	}
+}
+
+// files 按字段顺序收集表单中的文件
+func (h *UploadHandler) files(form *multipart.Form) ([]*multipart.FileHeader, error) {
+	var files []*multipart.FileHeader
+	for _, field := range h.config.FieldNames {
+		files = append(files, form.File[field]...)
+	}
+
+	if len(files) == 0 {
+		return nil, NoUploadFileErr
+	}
+	if len(files) > h.config.MaxFiles {
+		return nil, TooManyFilesErr
+	}
+
+	return files, nil
+}
+
+func (r UploadResponse) hasResult() bool {
+	for _, v := range r.Files {
+		if v.Result != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func uploadErrorResponse(err error) (int, UploadResponse) {
+	status := uploadErrorStatus(err)
+
+	// 服务端错误不向客户端暴露细节
+	message := err.Error()
+	if status >= http.StatusInternalServerError {
+		message = http.StatusText(status)
+	}
+
+	return status, UploadResponse{Error: message}
+}
+
+// uploadErrorStatus 将上传错误映射为 HTTP 状态码
+func uploadErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, FileTooLargeErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, FileTypeNotAllowedErr):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ObjectExistsErr):
+		return http.StatusConflict
+	case errors.Is(err, AccessDeniedErr), errors.Is(err, PathEscapeErr), errors.Is(err, ReservedPathErr):
+		return http.StatusForbidden
+	case errors.Is(err, NoUploadFileErr), errors.Is(err, TooManyFilesErr), errors.Is(err, http.ErrNotMultipart),
+		errors.Is(err, multipart.ErrMessageTooLarge), errors.Is(err, UnsupportedOptionErr), errors.Is(err, InvalidTagErr):
+		return http.StatusBadRequest
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeUploadResponse(w http.ResponseWriter, status int, resp UploadResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,107 @@
+package file_storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/qiuyier/file-storage/pkg/util"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func newUploadRequest(t *testing.T, files map[string][]byte) *http.Request {
+	t.Helper()
+
+	var buff bytes.Buffer
+	formWriter := multipart.NewWriter(&buff)
+	for name, content := range files {
+		formPart, err := formWriter.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = formPart.Write(content)
+	}
+	_ = formWriter.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &buff)
+	req.Header.Set("Content-Type", formWriter.FormDataContentType())
+
+	return req
+}
+
+func TestUploadHandler(t *testing.T) {
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: t.TempDir()})
+	uploader := NewFileUploader().RegisterUploader(local).SetValidation(ValidationRules{MaxSize: 10})
+	handler := NewUploadHandler(uploader, UploadHandlerConfig{MaxRequestSize: 1024})
+
+	serve := func(req *http.Request) (int, UploadResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var resp UploadResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, resp
+	}
+
+	status, resp := serve(newUploadRequest(t, map[string][]byte{"a.txt": []byte("hello")}))
+	if status != http.StatusOK || len(resp.Files) != 1 || resp.Files[0].Result == nil || resp.Files[0].Result.Checksum.MD5 == "" || resp.Files[0].Result.Path != "" {
+		t.Fatalf("unexpected response %d %+v", status, resp)
+	}
+
+	status, resp = serve(newUploadRequest(t, map[string][]byte{"a.txt": []byte("hello"), "b.txt": []byte("too large content")}))
+	if status != http.StatusMultiStatus || len(resp.Files) != 2 {
+		t.Fatalf("unexpected response %d %+v", status, resp)
+	}
+
+	if status, _ = serve(newUploadRequest(t, map[string][]byte{"b.txt": []byte("too large content")})); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for validation, got %d", status)
+	}
+	if status, _ = serve(newUploadRequest(t, map[string][]byte{"b.txt": bytes.Repeat([]byte("a"), 2048)})); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for request size, got %d", status)
+	}
+	if status, _ = serve(newUploadRequest(t, nil)); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 without file, got %d", status)
+	}
+	if status, _ = serve(httptest.NewRequest(http.MethodGet, "/upload", nil)); status != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", status)
+	}
+}
+
+func TestUploadHandlerStreaming(t *testing.T) {
+	dir := t.TempDir()
+	local, _ := NewUploaderLocal(UploaderLocalConfig{LocalPath: dir})
+	uploader := NewFileUploader().RegisterUploader(local).SetValidation(ValidationRules{MaxSize: 10})
+	handler := NewUploadHandler(uploader, UploadHandlerConfig{Streaming: true})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newUploadRequest(t, map[string][]byte{"a.txt": []byte("hello")}))
+
+	var resp UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(resp.Files) != 1 || resp.Files[0].Result == nil {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+
+	result := resp.Files[0].Result
+	if result.Size != util.FileSize(5) || result.ContentType != "text/plain; charset=utf-8" || result.Checksum.MD5 != "5d41402abc4b2a76b9719d911017c592" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newUploadRequest(t, map[string][]byte{"b.txt": []byte("too large content")}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for stream validation, got %d", w.Code)
+	}
+
+	// 超出大小的文件不应留在磁盘上
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "b.txt"))
+	if len(matches) != 0 {
+		t.Fatalf("partial file left: %v", matches)
+	}
+}
@@ -0,0 +1,172 @@
+package file_storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/qiuyier/file-storage/pkg/util"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+var (
+	FileTooSmallErr       = errors.New("file too small")
+	FileTooLargeErr       = errors.New("file too large")
+	FileTypeNotAllowedErr = errors.New("file type not allowed")
+	FileExtNotAllowedErr  = errors.New("file extension not allowed")
+	FileNameInvalidErr    = errors.New("file name invalid")
+)
+
+// ValidationError 上传校验失败，可通过 errors.Is 判断触发的规则
+type ValidationError struct {
+	FileName string
+	Err      error
+	Detail   string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validate file %s: %s, %s", e.FileName, e.Err.Error(), e.Detail)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationRules 上传校验规则，零值字段表示不限制
+type ValidationRules struct {
+	// MinSize 最小文件大小，单位byte
+	MinSize int64
+	// MaxSize 最大文件大小，单位byte
+	MaxSize int64
+	// AllowedTypes MIME 白名单，支持 image/* 形式的通配，类型通过文件头探测得到
+	AllowedTypes []string
+	// DeniedTypes MIME 黑名单，优先于白名单
+	DeniedTypes []string
+	// AllowedExts 扩展名白名单，不区分大小写，如 .png
+	AllowedExts []string
+	// MaxNameLength 文件名最大长度，单位byte
+	MaxNameLength int
+	// NamePattern 文件名需完整匹配的正则
+	NamePattern *regexp.Regexp
+	// DeniedNameChars 文件名中不允许出现的字符
+	DeniedNameChars string
+}
+
+// Validate 按规则校验文件，未通过时返回 *ValidationError
+func (r *ValidationRules) Validate(file Source) error {
+	if err := r.validateSize(file); err != nil {
+		return err
+	}
+
+	if err := r.validateName(file.Name()); err != nil {
+		return err
+	}
+
+	if len(r.AllowedTypes) == 0 && len(r.DeniedTypes) == 0 {
+		return nil
+	}
+
+	fd, err := file.Open()
+	if err != nil {
+		return errors.New("open file " + file.Name() + ", err: " + err.Error())
+	}
+	defer fd.Close()
+
+	// 忽略调用方声明的类型，只信任文件内容
+	return r.validateType(file.Name(), util.SniffContentType(fd, file.Name()))
+}
+
+func (r *ValidationRules) validateSize(file Source) error {
+	// 长度未知的数据源在读取时校验
+	if file.Size() < 0 {
+		return nil
+	}
+
+	if r.MinSize > 0 && file.Size() < r.MinSize {
+		return &ValidationError{
+			FileName: file.Name(),
+			Err:      FileTooSmallErr,
+			Detail:   fmt.Sprintf("size %s, min %s", util.FileSize(file.Size()), util.FileSize(r.MinSize)),
+		}
+	}
+
+	if r.MaxSize > 0 && file.Size() > r.MaxSize {
+		return &ValidationError{
+			FileName: file.Name(),
+			Err:      FileTooLargeErr,
+			Detail:   fmt.Sprintf("size %s, max %s", util.FileSize(file.Size()), util.FileSize(r.MaxSize)),
+		}
+	}
+
+	return nil
+}
+
+func (r *ValidationRules) validateName(fileName string) error {
+	if len(r.AllowedExts) > 0 {
+		ext := strings.ToLower(util.Ext(fileName))
+
+		allowed := false
+		for _, v := range r.AllowedExts {
+			if strings.ToLower(v) == ext {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return &ValidationError{FileName: fileName, Err: FileExtNotAllowedErr, Detail: "ext " + ext}
+		}
+	}
+
+	if r.MaxNameLength > 0 && len(fileName) > r.MaxNameLength {
+		return &ValidationError{
+			FileName: fileName,
+			Err:      FileNameInvalidErr,
+			Detail:   fmt.Sprintf("length %d, max %d", len(fileName), r.MaxNameLength),
+		}
+	}
+
+	if r.DeniedNameChars != "" && strings.ContainsAny(fileName, r.DeniedNameChars) {
+		return &ValidationError{FileName: fileName, Err: FileNameInvalidErr, Detail: "contains denied characters"}
+	}
+
+	if r.NamePattern != nil {
+		if loc := r.NamePattern.FindStringIndex(fileName); loc == nil || loc[0] != 0 || loc[1] != len(fileName) {
+			return &ValidationError{FileName: fileName, Err: FileNameInvalidErr, Detail: "not match " + r.NamePattern.String()}
+		}
+	}
+
+	return nil
+}
+
+func (r *ValidationRules) validateType(fileName, contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+
+	if matchMediaType(r.DeniedTypes, mediaType) {
+		return &ValidationError{FileName: fileName, Err: FileTypeNotAllowedErr, Detail: "type " + mediaType}
+	}
+
+	if len(r.AllowedTypes) > 0 && !matchMediaType(r.AllowedTypes, mediaType) {
+		return &ValidationError{FileName: fileName, Err: FileTypeNotAllowedErr, Detail: "type " + mediaType}
+	}
+
+	return nil
+}
+
+// matchMediaType 判断类型是否在列表中，支持 image/* 通配
+func matchMediaType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*/*" || pattern == mediaType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
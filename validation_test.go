@@ -0,0 +1,36 @@
+package file_storage
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestValidationRules(t *testing.T) {
+	rules := ValidationRules{
+		MaxSize:      10,
+		AllowedTypes: []string{"image/*"},
+		AllowedExts:  []string{".png", ".JPG"},
+		NamePattern:  regexp.MustCompile(`[\w.-]+`),
+	}
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	cases := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{"ok.png", png, nil},
+		{"ok.jpg", png, nil},
+		{"big.png", append(png, []byte("0123456789")...), FileTooLargeErr},
+		{"fake.png", []byte("%PDF-1.7"), FileTypeNotAllowedErr},
+		{"doc.pdf", png, FileExtNotAllowedErr},
+		{"bad name.png", png, FileNameInvalidErr},
+	}
+	for _, c := range cases {
+		err := rules.Validate(newSource(t, c.name, c.content))
+		if !errors.Is(err, c.want) {
+			t.Errorf("Validate(%s) = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
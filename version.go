@@ -0,0 +1,55 @@
+package file_storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ObjectVersion 对象的一个历史版本
+type ObjectVersion struct {
+	Path      string
+	VersionID string
+	Size      int64
+	ETag      string
+	// IsLatest 是否为当前版本
+	IsLatest bool
+	// IsDeleteMarker 删除标记，不包含数据，删除最新的删除标记即可撤销删除
+	IsDeleteMarker bool
+	LastModified   time.Time
+}
+
+func (u *Uploader) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	versions, err := u.uploader.ListVersions(ctx, path)
+	if err != nil {
+		u.logger.Errorf("list versions %s err: %v", path, err)
+	}
+
+	return versions, err
+}
+
+func (u *Uploader) DeleteVersion(ctx context.Context, path, versionID string) error {
+	err := u.uploader.DeleteVersion(ctx, path, versionID)
+	if err != nil {
+		u.logger.Errorf("delete version %s %s err: %v", path, versionID, err)
+	}
+
+	return err
+}
+
+// RestoreVersion 将指定版本恢复为最新版本，返回新版本号，原有版本全部保留
+func (u *Uploader) RestoreVersion(ctx context.Context, path, versionID string) (string, error) {
+	newVersionID, err := u.uploader.RestoreVersion(ctx, path, versionID)
+	if err != nil {
+		u.logger.Errorf("restore version %s %s err: %v", path, versionID, err)
+	}
+
+	return newVersionID, err
+}
+
+// sortVersions 厂商分别返回版本和删除标记，合并后按时间由新到旧排列
+func sortVersions(versions []ObjectVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+}